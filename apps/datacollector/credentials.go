@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/andrej220/HAM/pkg/executor"
+	gp "github.com/andrej220/HAM/pkg/graphproc"
+	"golang.org/x/crypto/ssh"
+)
+
+// newCredentialRegistry registers the credential providers enabled in the
+// credentials section of the configuration.
+func newCredentialRegistry(cfg *DataCollectorConfig) *executor.CredentialRegistry {
+	cc := cfg.Credentials
+	reg := executor.NewCredentialRegistry()
+
+	if cc.KeyDir != "" {
+		reg.Register(executor.CredentialSourceFile, executor.NewKeyFileProvider(cc.KeyDir))
+	}
+	if cc.EnvPrefix != "" {
+		reg.Register(executor.CredentialSourceEnv, executor.NewEnvProvider(cc.EnvPrefix))
+	}
+	reg.Register(executor.CredentialSourceAgent, executor.NewAgentProvider(cc.AgentSocket))
+	if cc.VaultFile != "" && cc.VaultPassphraseEnv != "" {
+		passphrase := os.Getenv(cc.VaultPassphraseEnv)
+		if passphrase != "" {
+			reg.Register(executor.CredentialSourceVault, executor.NewVaultProvider(cc.VaultFile, []byte(passphrase)))
+		}
+	}
+	return reg
+}
+
+// hostAuth resolves the login and auth methods for the graph's host.
+// Hosts without a credential source fall back to the password and key
+// path of the script document. The returned cleanup must be called
+// once the connection is established.
+func (h *datacollectorHandler) hostAuth(ctx context.Context, graph *gp.Graph) (string, []ssh.AuthMethod, func(), error) {
+	user := graph.Config.Login
+	if hc := graph.HostCfg; hc != nil && hc.HostUser != "" {
+		user = hc.HostUser
+	}
+
+	if hc := graph.HostCfg; hc != nil && hc.CredentialSource != "" {
+		cred, err := h.credentials.Resolve(ctx, hc.CredentialSource, hc.CredentialRef)
+		if err != nil {
+			return "", nil, nil, err
+		}
+		if cred.User != "" {
+			user = cred.User
+		}
+		return user, cred.AuthMethods(), func() { cred.Close() }, nil
+	}
+
+	// Legacy: credentials inline in the script document.
+	var auth []ssh.AuthMethod
+	if graph.Config.SSHKeyPath != "" {
+		keyAuth, err := publicKeyAuth(graph.Config.SSHKeyPath)
+		if err != nil {
+			return "", nil, nil, err
+		}
+		auth = append(auth, keyAuth)
+	}
+	if graph.Config.Password != "" {
+		auth = append(auth, ssh.Password(graph.Config.Password))
+	}
+	if len(auth) == 0 {
+		return "", nil, nil, fmt.Errorf("no credentials configured for host")
+	}
+	return user, auth, func() {}, nil
+}
+
+// jumpHops builds the client configs for the jump hosts of the graph's
+// host. Hops without their own user or credential source use those of the
+// host, given as user and auth. The returned cleanup must be called once
+// the connection is established.
+func (h *datacollectorHandler) jumpHops(ctx context.Context, graph *gp.Graph, user string, auth []ssh.AuthMethod) ([]executor.Hop, func(), error) {
+	if graph.HostCfg == nil || len(graph.HostCfg.JumpHosts) == 0 {
+		return nil, func() {}, nil
+	}
+	var closers []func()
+	cleanup := func() {
+		for _, c := range closers {
+			c()
+		}
+	}
+
+	hops := make([]executor.Hop, 0, len(graph.HostCfg.JumpHosts))
+	for _, jh := range graph.HostCfg.JumpHosts {
+		hopUser, hopAuth := user, auth
+		if jh.CredentialSource != "" {
+			cred, err := h.credentials.Resolve(ctx, jh.CredentialSource, jh.CredentialRef)
+			if err != nil {
+				cleanup()
+				return nil, nil, fmt.Errorf("jump host %s: %w", jh.Addr(), err)
+			}
+			closers = append(closers, func() { cred.Close() })
+			if cred.User != "" {
+				hopUser = cred.User
+			}
+			hopAuth = cred.AuthMethods()
+		}
+		if jh.User != "" {
+			hopUser = jh.User
+		}
+		callback, err := h.hopHostKeyCallback(jh.HostKeyPolicy)
+		if err != nil {
+			cleanup()
+			return nil, nil, fmt.Errorf("jump host %s: %w", jh.Addr(), err)
+		}
+		hops = append(hops, executor.Hop{
+			Addr: jh.Addr(),
+			Config: &ssh.ClientConfig{
+				User:            hopUser,
+				Auth:            hopAuth,
+				HostKeyCallback: callback,
+				Timeout:         10 * time.Second,
+				BannerCallback:  func(message string) error { return nil },
+			},
+		})
+	}
+	return hops, cleanup, nil
+}
+
+// becomePassword returns the password to answer sudo or su with: that of
+// the become credential, else the host's credential or the password of
+// the script document.
+func (h *datacollectorHandler) becomePassword(ctx context.Context, graph *gp.Graph, b *gp.Become) (string, error) {
+	source, ref := b.CredentialSource, b.CredentialRef
+	if source == "" && graph.HostCfg != nil {
+		source, ref = graph.HostCfg.CredentialSource, graph.HostCfg.CredentialRef
+	}
+	if source == "" {
+		return graph.Config.Password, nil
+	}
+	cred, err := h.credentials.Resolve(ctx, source, ref)
+	if err != nil {
+		return "", err
+	}
+	defer cred.Close()
+	return cred.Password, nil
+}
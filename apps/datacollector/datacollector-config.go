@@ -1,5 +1,11 @@
 package main
 
+import (
+	"time"
+
+	"github.com/andrej220/HAM/pkg/executor"
+)
+
 const SERVICENAME = "datacollector"
 const CONFIGFILENAME = "config.yaml"
 const PROJECTNAME = "HAM"
@@ -13,12 +19,63 @@ type DataCollectorConfig struct{
 		Brokers []string `yaml:"brokers" json:"brokers"`
 		Topic   string   `yaml:"topic" json:"topic"`
 		GroupID string   `yaml:"groupID" json:"groupID"`
+		// RedeliveryDelay is the wait before a failed request is run
+		// again; after MaxDeliveries runs it is dropped.
+		RedeliveryDelay time.Duration `yaml:"redeliveryDelay" json:"redeliveryDelay"`
+		MaxDeliveries   int           `yaml:"maxDeliveries" json:"maxDeliveries"`
 	} `yaml:"kafka" json:"kafka"`
 	
 	Database struct {
 		MongoURI string `yaml:"mongoURI" json:"mongoURI"`
 		DBName   string `yaml:"dbName" json:"dbName"`
 	} `yaml:"database" json:"database"`
+
+	HostKeys struct {
+		Policy         string `yaml:"policy" json:"policy"`                 // strict | tofu | insecure
+		Store          string `yaml:"store" json:"store"`                   // file | mongo
+		KnownHostsFile string `yaml:"knownHostsFile" json:"knownHostsFile"`
+		Collection     string `yaml:"collection" json:"collection"`
+	} `yaml:"hostKeys" json:"hostKeys"`
+
+	Credentials struct {
+		KeyDir             string `yaml:"keyDir" json:"keyDir"`
+		EnvPrefix          string `yaml:"envPrefix" json:"envPrefix"`
+		AgentSocket        string `yaml:"agentSocket" json:"agentSocket"`               // defaults to $SSH_AUTH_SOCK
+		VaultFile          string `yaml:"vaultFile" json:"vaultFile"`
+		VaultPassphraseEnv string `yaml:"vaultPassphraseEnv" json:"vaultPassphraseEnv"` // env var holding the vault passphrase
+	} `yaml:"credentials" json:"credentials"`
+
+	Repository struct {
+		Type              string `yaml:"type" json:"type"` // file | mongo
+		ScriptsDir        string `yaml:"scriptsDir" json:"scriptsDir"`
+		HostsDir          string `yaml:"hostsDir" json:"hostsDir"`
+		ScriptsCollection string `yaml:"scriptsCollection" json:"scriptsCollection"`
+		HostsCollection   string `yaml:"hostsCollection" json:"hostsCollection"`
+		InlineHosts       bool   `yaml:"inlineHosts" json:"inlineHosts"` // development only, see repository.LoadGraph
+	} `yaml:"repository" json:"repository"`
+
+	// Executions is the store the execution states are recorded in,
+	// shared with the producer and the dataservice.
+	Executions struct {
+		Store      string `yaml:"store" json:"store"` // none | mongo
+		Collection string `yaml:"collection" json:"collection"`
+		// CompletedTTL is how long delivered ExecutionUIDs are remembered
+		// to skip duplicate requests.
+		CompletedTTL time.Duration `yaml:"completedTTL" json:"completedTTL"`
+	} `yaml:"executions" json:"executions"`
+
+	SSHPool executor.PoolConfig `yaml:"sshPool" json:"sshPool"`
+
+	// JobTimeout bounds a whole collection job, connecting included.
+	JobTimeout time.Duration `yaml:"jobTimeout" json:"jobTimeout"`
+
+	// NodeLimits apply to nodes without their own timeout or output limits.
+	NodeLimits executor.Limits `yaml:"nodeLimits" json:"nodeLimits"`
+}
+
+// needsMongo reports whether any configured store is backed by MongoDB.
+func (c *DataCollectorConfig) needsMongo() bool {
+	return c.HostKeys.Store == "mongo" || c.Repository.Type == "mongo" || c.Executions.Store == "mongo"
 }
 
 func NewDataCollectorConfig() *DataCollectorConfig{
@@ -10,6 +10,7 @@ import (
 	"os/signal"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -27,9 +28,16 @@ import (
 	"errors"
 	"github.com/segmentio/kafka-go"
 	"math"
+	"github.com/andrej220/HAM/pkg/executor"
+	"github.com/andrej220/HAM/pkg/execstatus"
+	"github.com/andrej220/HAM/pkg/repository"
+	"go.mongodb.org/mongo-driver/mongo"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/ssh"
 )
 
 const MAXTIMEOUT time.Duration = 1 * time.Minute
+const defaultJobTimeout = 30 * time.Minute
 const DATASERVICEURL = "http://localhost:8082/dataservice"
 
 type datacollectorHandler struct {
@@ -37,15 +45,43 @@ type datacollectorHandler struct {
 	cancelFuncs  sync.Map
 	httpClient  *http.Client
 	logger		 lg.Logger
+	hostKeyCallback ssh.HostKeyCallback
+	hostKeyStore executor.HostKeyStore
+	credentials *executor.CredentialRegistry
+	scripts     repository.ScriptRepository
+	hosts       repository.HostRepository
+	inlineHosts bool // fall back to the host of the script document
+	connPool    *executor.ConnPool
+	nodeLimits  executor.Limits
+	executions  execstatus.Store
+	completed   *completedSet
+	jobTimeout  time.Duration
+	inFlight    sync.Map // ExecutionUIDs of running jobs
 }
 
-func newDatacollectorHandler(lg lg.Logger) *datacollectorHandler {
+func newDatacollectorHandler(lg lg.Logger, hostKeyCallback ssh.HostKeyCallback, hostKeyStore executor.HostKeyStore,
+	credentials *executor.CredentialRegistry,
+	scripts repository.ScriptRepository, hosts repository.HostRepository, connPool *executor.ConnPool,
+	nodeLimits executor.Limits, executions execstatus.Store, completedTTL, jobTimeout time.Duration) *datacollectorHandler {
 	h := &datacollectorHandler{
 		pool: workerpool.NewPool[SSHJob](workerpool.TotalMaxWorkers),
 		httpClient: &http.Client{
 			Timeout: 10 * time.Second,
 		},
 		logger: lg,
+		hostKeyCallback: hostKeyCallback,
+		hostKeyStore: hostKeyStore,
+		credentials: credentials,
+		scripts: scripts,
+		hosts: hosts,
+		connPool: connPool,
+		nodeLimits: nodeLimits,
+		executions: executions,
+		completed: newCompletedSet(completedTTL),
+		jobTimeout: jobTimeout,
+	}
+	if h.jobTimeout <= 0 {
+		h.jobTimeout = defaultJobTimeout
 	}
 	return h
 }
@@ -78,28 +114,90 @@ func SendToDataservice(gr *gp.Graph, httpClient *http.Client) error {
 	return nil
 }
 
-func Serve(data dm.Request, h *datacollectorHandler, ctx context.Context ) {
+// deliver sends the graph to the dataservice, which records it as stored.
+func (h *datacollectorHandler) deliver(j SSHJob, graph *gp.Graph) error {
+	h.setState(j.UUID, execstatus.Sending, nil)
+	if err := SendToDataservice(graph, h.httpClient); err != nil {
+		h.setState(j.UUID, execstatus.Failed, err)
+		return err
+	}
+	if j.UUID != uuid.Nil {
+		h.completed.Add(j.UUID)
+	}
+	return nil
+}
+
+// settle acks a request whose graph reached the dataservice and nacks it
+// otherwise, so Kafka redelivers it.
+func (h *datacollectorHandler) settle(msg *ku.Message[dm.Request], delivered bool) {
+	uid := msg.Value.ExecutionUID
+	if delivered {
+		if err := msg.Ack(); err != nil {
+			h.logger.Error("Failed to commit request", lg.String("exuid", uid.String()), lg.Any("error", err))
+		}
+		return
+	}
+	err := msg.Nack()
+	if errors.Is(err, ku.ErrDropped) {
+		h.logger.Error("Giving up on request", lg.String("exuid", uid.String()), lg.Int("deliveries", msg.Deliveries))
+		h.setState(uid, execstatus.Failed, err)
+		return
+	}
+	h.logger.Warn("Request will be redelivered", lg.String("exuid", uid.String()), lg.Int("deliveries", msg.Deliveries))
+}
+
+// Serve runs the collection request of msg. The message is acked once the
+// graph is delivered to the dataservice, or right away for duplicates.
+func Serve(msg *ku.Message[dm.Request], h *datacollectorHandler, ctx context.Context ) {
+	data := msg.Value
+	if skip, reason := h.skipExecution(data.ExecutionUID); skip {
+		h.logger.Info("Skipping duplicate request", lg.String("exuid", data.ExecutionUID.String()), lg.String("reason", reason))
+		h.settle(msg, true)
+		return
+	}
+	if data.ExecutionUID != uuid.Nil {
+		h.inFlight.Store(data.ExecutionUID, struct{}{})
+	}
+	// a job that never finishes would hold its Kafka offset forever
+	ctx, cancel := context.WithTimeout(ctx, h.jobTimeout)
 
 	sshJob := SSHJob{
 		HostID:   data.HostID,
 		ScriptID: data.ScriptID,
 		UUID:     data.ExecutionUID,
+		BatchID:  data.BatchID,
 		Ctx:      ctx,
 	}
 
+	var delivered atomic.Bool
 	jb := workerpool.Job[SSHJob]{
 		Payload: sshJob,
 		Fn:     func(j SSHJob) error {
-					graph, err := RunJob(j)
+					h.setState(j.UUID, execstatus.Running, nil)
+					graph, err := h.RunJob(j)
+					if errors.Is(err, executor.ErrHostKeyUnknown) || errors.Is(err, executor.ErrHostKeyChanged) {
+						// retrying cannot help, report the failure with the graph
+						h.logger.Error("Host key verification failed", lg.Any("error", err))
+						graph.Error = err.Error()
+						graph.Status = gp.JobFailed
+						err = h.deliver(j, graph)
+						delivered.Store(err == nil)
+						return err
+					}
 					if err != nil{
+						h.setState(j.UUID, execstatus.Failed, err)
 						return err
 					}
 					//logger.Info("Request to dataservice")
-					SendToDataservice(graph,h.httpClient)
+					delivered.Store(h.deliver(j, graph) == nil)
 					return nil
 				},
 		Ctx:     ctx,
+		// runs after Fn returned, even on timeout, so delivered is final
 		CleanupFunc: func() {
+			h.inFlight.Delete(data.ExecutionUID)
+			h.settle(msg, delivered.Load())
+			cancel()
 			if cancel, ok := h.cancelFuncs.Load(data.ExecutionUID); ok {
 				cancel.(context.CancelFunc)()
 				h.cancelFuncs.Delete(data.ExecutionUID)
@@ -124,11 +222,49 @@ func initConfig(path string)(*DataCollectorConfig, error){
 func main() {
 	loggercfg := lg.NewConfigFromFlags(SERVICENAME)
 	logger := lg.New(loggercfg)
-	handler := newDatacollectorHandler(logger)
 	
 	cfg, err := initConfig(config.GetConfigPath(PROJECTNAME, SERVICENAME, CONFIGFILENAME))
 	if err != nil {
 		logger.Error("Setting configuration failed: ", lg.Any("error",err))
+		os.Exit(1)
+	}
+
+	var mdb *mongo.Client
+	if cfg.needsMongo() {
+		mdb, err = connectMongo(cfg.Database.MongoURI)
+		if err != nil {
+			logger.Error("MongoDB connection failed", lg.Any("error", err))
+			os.Exit(1)
+		}
+		defer mdb.Disconnect(context.Background())
+	}
+
+	hostKeyStore, err := newHostKeyStore(cfg, mdb)
+	if err != nil {
+		logger.Error("Host key store setup failed", lg.Any("error", err))
+		os.Exit(1)
+	}
+	hostKeyCallback, err := newHostKeyCallback(cfg, hostKeyStore)
+	if err != nil {
+		logger.Error("Host key verification setup failed", lg.Any("error", err))
+		os.Exit(1)
+	}
+	scripts, hosts, err := newRepositories(cfg, mdb)
+	if err != nil {
+		logger.Error("Repository setup failed", lg.Any("error", err))
+		os.Exit(1)
+	}
+	connPool := executor.NewConnPool(cfg.SSHPool)
+	defer connPool.Close()
+	executions, err := newExecutionStore(cfg, mdb)
+	if err != nil {
+		logger.Error("Execution store setup failed", lg.Any("error", err))
+		os.Exit(1)
+	}
+	handler := newDatacollectorHandler(logger, hostKeyCallback, hostKeyStore, newCredentialRegistry(cfg), scripts, hosts, connPool, cfg.NodeLimits, executions, cfg.Executions.CompletedTTL, cfg.JobTimeout)
+	if cfg.Repository.InlineHosts {
+		logger.Warn("Hosts without a document run on the remote_host of the script, use for development only")
+		handler.inlineHosts = true
 	}
 	
 	// Set up Kafka consumer
@@ -136,13 +272,17 @@ func main() {
 		Brokers: cfg.Kafka.Brokers,
 		Topic:   cfg.Kafka.Topic,
 		GroupID: cfg.Kafka.GroupID,
+		RedeliveryDelay: cfg.Kafka.RedeliveryDelay,
+		MaxDeliveries:   cfg.Kafka.MaxDeliveries,
 	}
 
 	logger.Info("Starting "+ SERVICENAME +" service",
 		lg.Int("port : ", cfg.Server.Port),
 		lg.String("kafka_brokers : ", strings.Join(cfg.Kafka.Brokers, ", ")))
 
-	cons := ku.NewConsumer[dm.Request](consumerCfg)
+	// offsets are committed once a request's graph is delivered, so a
+	// crash redelivers every unfinished request
+	cons := ku.NewAckConsumer[dm.Request](consumerCfg)
 	defer cons.Close()
 
 	// Create a context that can be cancelled
@@ -159,7 +299,7 @@ func main() {
 		defer close(done)
 	    backoff := time.Second 
 		for {
-			order, err := cons.Read(ctx)
+			order, err := cons.Fetch(ctx)
 			if err != nil {
 				if errors.Is(err, context.Canceled) {
 					logger.Info("Shutting down consumer loop...")
@@ -173,12 +313,17 @@ func main() {
 					backoff = time.Duration(math.Min(float64(backoff*2), float64(10*time.Second)))
 					continue
 				}
+				var decodeErr *ku.DecodeError
+				if errors.As(err, &decodeErr) {
+					logger.Error("Dropping undecodable request", lg.Any("err", err))
+					continue
+				}
 				logger.Error("Unexpected error", lg.Any("err", err))
 				continue
 			}
 		
-			logger.Debug("Received msg", lg.Any("order", order))
-			fmt.Println("Received msg:", order)
+			logger.Debug("Received msg", lg.Any("order", order.Value), lg.Int("deliveries", order.Deliveries))
+			fmt.Println("Received msg:", order.Value)
 			Serve(order, handler, ctx)
 		}
 	}()
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/andrej220/HAM/pkg/execstatus"
+	"github.com/andrej220/HAM/pkg/lg"
+	"github.com/google/uuid"
+)
+
+const defaultCompletedTTL = time.Hour
+
+// completedSet remembers the executions this collector delivered, for
+// requests redelivered by Kafka or retried by clients.
+type completedSet struct {
+	mu        sync.Mutex
+	ttl       time.Duration
+	entries   map[uuid.UUID]time.Time
+	lastPrune time.Time
+}
+
+func newCompletedSet(ttl time.Duration) *completedSet {
+	if ttl <= 0 {
+		ttl = defaultCompletedTTL
+	}
+	return &completedSet{ttl: ttl, entries: make(map[uuid.UUID]time.Time)}
+}
+
+func (s *completedSet) Add(uid uuid.UUID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	s.entries[uid] = now.Add(s.ttl)
+	if now.Sub(s.lastPrune) > time.Minute {
+		for k, exp := range s.entries {
+			if now.After(exp) {
+				delete(s.entries, k)
+			}
+		}
+		s.lastPrune = now
+	}
+}
+
+func (s *completedSet) Has(uid uuid.UUID) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	exp, ok := s.entries[uid]
+	return ok && time.Now().Before(exp)
+}
+
+// skipExecution reports whether a request must not run: its execution is
+// already running here or was completed, as remembered locally or
+// recorded in the execution store. Requests without an ExecutionUID are
+// never skipped.
+func (h *datacollectorHandler) skipExecution(uid uuid.UUID) (bool, string) {
+	if uid == uuid.Nil {
+		return false, ""
+	}
+	if h.completed.Has(uid) {
+		return true, "already completed"
+	}
+	if _, running := h.inFlight.Load(uid); running {
+		return true, "already running"
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	e, err := h.executions.Get(ctx, uid)
+	if err == nil && e.State == execstatus.Stored {
+		return true, "already stored"
+	}
+	if err != nil && !errors.Is(err, execstatus.ErrNotFound) {
+		// tracking is best effort, run rather than drop the request
+		h.logger.Warn("Failed to look up execution", lg.String("exuid", uid.String()), lg.Any("error", err))
+	}
+	return false, ""
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/andrej220/HAM/pkg/execstatus"
+	"github.com/andrej220/HAM/pkg/lg"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const defaultExecutionsCollection = "executions"
+
+// newExecutionStore returns the store execution states are recorded in,
+// or execstatus.Discard if tracking is off.
+func newExecutionStore(cfg *DataCollectorConfig, mdb *mongo.Client) (execstatus.Store, error) {
+	switch cfg.Executions.Store {
+	case "", "none":
+		return execstatus.Discard, nil
+	case "mongo":
+		if mdb == nil {
+			return nil, fmt.Errorf("execution store %q requires database.mongoURI", cfg.Executions.Store)
+		}
+		coll := mdb.Database(cfg.Database.DBName).Collection(orDefault(cfg.Executions.Collection, defaultExecutionsCollection))
+		return execstatus.NewMongoStore(coll), nil
+	default:
+		return nil, fmt.Errorf("unknown execution store %q", cfg.Executions.Store)
+	}
+}
+
+// setState records the state of an execution. Tracking failures are only
+// logged, they must not fail the job.
+func (h *datacollectorHandler) setState(uid uuid.UUID, state execstatus.State, err error) {
+	var msg string
+	if err != nil {
+		msg = err.Error()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := h.executions.Transition(ctx, uid, state, msg); err != nil {
+		h.logger.Warn("Failed to record execution state",
+			lg.String("exuid", uid.String()), lg.String("state", string(state)), lg.Any("error", err))
+	}
+}
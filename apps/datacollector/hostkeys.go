@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/andrej220/HAM/pkg/executor"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	defaultKnownHostsFile     = "/etc/ham/known_hosts"
+	defaultHostKeysCollection = "hostkeys"
+)
+
+// newHostKeyCallback builds the host key verification callback from the
+// hostKeys section of the configuration. Strict verification against the
+// known_hosts file is used when the section is empty.
+func newHostKeyCallback(cfg *DataCollectorConfig, store executor.HostKeyStore) (ssh.HostKeyCallback, error) {
+	policy := executor.HostKeyPolicy(cfg.HostKeys.Policy)
+	if policy == "" {
+		policy = executor.HostKeyStrict
+	}
+	return executor.NewHostKeyCallback(policy, store)
+}
+
+// newHostKeyStore opens the store of trusted host keys. It is created even
+// with the insecure policy since jump hosts may ask for verification.
+func newHostKeyStore(cfg *DataCollectorConfig, mdb *mongo.Client) (executor.HostKeyStore, error) {
+	hk := cfg.HostKeys
+	switch hk.Store {
+	case "", "file":
+		return executor.NewKnownHostsFile(orDefault(hk.KnownHostsFile, defaultKnownHostsFile)), nil
+	case "mongo":
+		if mdb == nil {
+			return nil, fmt.Errorf("host key store %q requires database.mongoURI", hk.Store)
+		}
+		coll := mdb.Database(cfg.Database.DBName).Collection(orDefault(hk.Collection, defaultHostKeysCollection))
+		return executor.NewMongoHostKeyStore(coll), nil
+	default:
+		return nil, fmt.Errorf("unknown host key store %q", hk.Store)
+	}
+}
+
+// hopHostKeyCallback returns the callback for a jump host: the collector's
+// default, or one enforcing the policy given for the hop.
+func (h *datacollectorHandler) hopHostKeyCallback(policy string) (ssh.HostKeyCallback, error) {
+	if policy == "" {
+		return h.hostKeyCallback, nil
+	}
+	return executor.NewHostKeyCallback(executor.HostKeyPolicy(policy), h.hostKeyStore)
+}
+
+func connectMongo(uri string) (*mongo.Client, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to MongoDB: %w", err)
+	}
+	if err := client.Ping(ctx, nil); err != nil {
+		return nil, fmt.Errorf("failed to ping MongoDB: %w", err)
+	}
+	return client, nil
+}
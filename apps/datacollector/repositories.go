@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/andrej220/HAM/pkg/repository"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	defaultScriptsDir        = "/etc/ham/scripts"
+	defaultHostsDir          = "/etc/ham/hosts"
+	defaultScriptsCollection = "scripts"
+	defaultHostsCollection   = "hosts"
+)
+
+// newRepositories builds the script and host repositories from the
+// repository section of the configuration.
+func newRepositories(cfg *DataCollectorConfig, mdb *mongo.Client) (repository.ScriptRepository, repository.HostRepository, error) {
+	rc := cfg.Repository
+	switch rc.Type {
+	case "", "file":
+		return repository.NewFileScriptRepository(orDefault(rc.ScriptsDir, defaultScriptsDir)),
+			repository.NewFileHostRepository(orDefault(rc.HostsDir, defaultHostsDir)), nil
+	case "mongo":
+		if mdb == nil {
+			return nil, nil, fmt.Errorf("repository %q requires database.mongoURI", rc.Type)
+		}
+		db := mdb.Database(cfg.Database.DBName)
+		return repository.NewMongoScriptRepository(db.Collection(orDefault(rc.ScriptsCollection, defaultScriptsCollection))),
+			repository.NewMongoHostRepository(db.Collection(orDefault(rc.HostsCollection, defaultHostsCollection))), nil
+	default:
+		return nil, nil, fmt.Errorf("unknown repository type %q", rc.Type)
+	}
+}
+
+func orDefault(v, def string) string {
+	if v == "" {
+		return def
+	}
+	return v
+}
@@ -5,9 +5,9 @@ import (
 	"fmt"
 	"io"
 	"log"
-	"golang.org/x/sync/errgroup"
 	gp "github.com/andrej220/HAM/pkg/graphproc"
 	"github.com/andrej220/HAM/pkg/executor"
+	"github.com/andrej220/HAM/pkg/repository"
 	"github.com/google/uuid"
 	"golang.org/x/crypto/ssh"
 	"time"
@@ -15,7 +15,6 @@ import (
 )
 
 const (
-	MAXLINES      = 50
 	maxConcurrent = 7
 )
 
@@ -27,6 +26,7 @@ type SSHJob struct {
 	HostID   int
 	ScriptID int
 	UUID     uuid.UUID
+	BatchID  uuid.UUID
 	Ctx      context.Context
 }
 
@@ -77,67 +77,132 @@ func (t * task)Run(script string, ctx context.Context)(stdout io.Reader, stderr
 	return stdout, stderr, nil
 }
 
-func loadGraphConfig(jb SSHJob)(*gp.Graph, error){
-
-	//TODO: load task from the database
-	graph, err := gp.NewGraphFromJSON("/etc/ham/docconfig.json")
+func (h *datacollectorHandler) loadGraphConfig(jb SSHJob)(*gp.Graph, error){
+	graph, err := repository.LoadGraph(jb.Ctx, h.scripts, h.hosts, jb.ScriptID, jb.HostID, h.inlineHosts)
 	if err != nil {
-		log.Printf("Error reading configuration %+v", err)
+		log.Printf("Error loading script %d for host %d: %+v", jb.ScriptID, jb.HostID, err)
 		return nil, err
 	}
+	if err := gp.ValidateConfig(graph.Config); err != nil {
+		return nil, fmt.Errorf("script %d: %w", jb.ScriptID, err)
+	}
 	graph.UUID = jb.UUID
-
-	// TODO: delete it, just for the test. Should be populated from the database
-	graph.HostCfg = &gp.HostConfig{
-		CustomerID: 	1,
-		HostID:     	jb.HostID,
-		ScriptID: 		jb.ScriptID,
+	if jb.BatchID != uuid.Nil {
+		graph.BatchID = jb.BatchID.String()
 	}
 	return graph, nil
 }
 
-func RunJob(jb SSHJob) (*gp.Graph, error) {
-	log.Printf("Starting job for host %d, script %d, UUID %s", jb.HostID, jb.ScriptID, jb.UUID)
-    graph, err := loadGraphConfig(jb)
-    if err != nil {
-        return nil, err
-    }
-	var sshkeyAuth ssh.AuthMethod
-	sshkeyAuth, err = publicKeyAuth(graph.Config.SSHKeyPath) 
+// newExecutor returns the executor for the host of the graph, wrapped to
+// run inside the configured container if there is one.
+func (h *datacollectorHandler) newExecutor(jb SSHJob, graph *gp.Graph) (executor.Executor, func(), error) {
+	exec, closeExec, err := h.hostExecutor(jb, graph)
+	if err != nil || graph.HostCfg == nil || graph.HostCfg.Container == "" {
+		return exec, closeExec, err
+	}
+	cexec, err := executor.NewContainerExecutor(exec, graph.HostCfg.ContainerRuntime, graph.HostCfg.Container)
+	if err != nil {
+		closeExec()
+		return nil, nil, err
+	}
+	return cexec, closeExec, nil
+}
+
+// nodeExecutor wraps exec to run the node's script as another user if the
+// node asks for it.
+func (h *datacollectorHandler) nodeExecutor(ctx context.Context, graph *gp.Graph, node *gp.Node, exec executor.Executor) (executor.Executor, error) {
+	b := node.Become
+	if b == nil {
+		return exec, nil
+	}
+	password, err := h.becomePassword(ctx, graph, b)
+	if err != nil {
+		return nil, fmt.Errorf("become: %w", err)
+	}
+	return executor.NewBecomeExecutor(exec, executor.Become{
+		Method:   b.Method,
+		User:     b.User,
+		Password: password,
+		PTY:      b.TTY,
+	})
+}
+
+// hostExecutor returns a local executor for hosttype "local", otherwise an
+// SSH executor on a pooled connection, through the host's jump hosts if it
+// has any.
+func (h *datacollectorHandler) hostExecutor(jb SSHJob, graph *gp.Graph) (executor.Executor, func(), error) {
+	if graph.HostCfg != nil && graph.HostCfg.HostType == gp.HostTypeLocal {
+		return executor.NewLocalExecutor(), func() {}, nil
+	}
+	user, auth, closeCred, err := h.hostAuth(jb.Ctx, graph)
 	if err != nil {
-		log.Printf("Failed parsing ssh keys, %v", err)
-		return graph, err
+		log.Printf("Failed resolving credentials, %v", err)
+		return nil, nil, err
 	}
-	auth := []ssh.AuthMethod{ssh.Password(graph.Config.Password), sshkeyAuth}
 	clientConfig := &ssh.ClientConfig{
-		User: graph.Config.Login,
+		User: user,
 		Auth:            auth, 
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		HostKeyCallback: h.hostKeyCallback,
 		Timeout:         10 * time.Second,
 		BannerCallback:  func(message string) error { return nil }, //ignore banner
 	}
 
-    rclient, err := executor.NewResilientClient( graph.Config.RemoteHost, clientConfig )
+	hops, closeHops, err := h.jumpHops(jb.Ctx, graph, user, auth)
+	if err != nil {
+		closeCred()
+		return nil, nil, err
+	}
+
+	rclient, err := h.connPool.GetVia(jb.Ctx, hops, graph.RemoteAddr(), clientConfig)
+	if err != nil {
+		closeHops()
+		closeCred()
+		return nil, nil, fmt.Errorf("ssh dial: %w", err)
+	}
+	return executor.NewSSHExecutor(rclient), func() {
+		rclient.Close()
+		closeHops()
+		closeCred()
+	}, nil
+}
 
+func (h *datacollectorHandler) RunJob(jb SSHJob) (*gp.Graph, error) {
+	log.Printf("Starting job for host %d, script %d, UUID %s", jb.HostID, jb.ScriptID, jb.UUID)
+    graph, err := h.loadGraphConfig(jb)
     if err != nil {
-        return nil, fmt.Errorf("ssh dial: %w", err)
+        return nil, err
+    }
+    exec, closeExec, err := h.newExecutor(jb, graph)
+    if err != nil {
+        return graph, err
     }
-    defer rclient.Close()
-
-    exec := executor.NewSSHExecutor(rclient)
-    g, ctx := errgroup.WithContext(jb.Ctx)
-    for i := 0; i < maxConcurrent; i++ {
-        g.Go(func() error {
-            for node := range graph.NodeGenerator() {
-                task := executor.NewNodeTask(node, exec)  
-                if err := task.Execute(ctx); err != nil {
-                    return err
-                }
-            }
-            return nil
-        })
+    defer closeExec()
+
+    err = graph.Execute(jb.Ctx, maxConcurrent, func(ctx context.Context, node *gp.Node) error {
+        script, err := graph.RenderScript(node)
+        if err != nil {
+            node.RunError = err.Error()
+            node.Finish(gp.StatusFailed)
+            return err
+        }
+        nodeExec, err := h.nodeExecutor(ctx, graph, node, exec)
+        if err != nil {
+            node.RunError = err.Error()
+            node.Finish(gp.StatusFailed)
+            return err
+        }
+        task := executor.NewNodeTask(node, nodeExec)
+        task.Script = script
+        task.Defaults = h.nodeLimits
+        return task.Execute(ctx)
+    })
+    if err != nil {
+        // node failures are part of the result: the graph carries the
+        // job status and error and is stored like any other run
+        log.Printf("Job %s stopped: %v", jb.UUID, err)
     }
-    return graph, g.Wait()
+    log.Printf("Job %s finished with status %s", jb.UUID, graph.Status)
+    return graph, nil
 }
 
 func publicKeyAuth(privateKeyPath string) (ssh.AuthMethod, error) {
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/andrej220/HAM/pkg/execstatus"
+	"github.com/andrej220/HAM/pkg/lg"
+	"github.com/andrej220/HAM/pkg/repository"
+	"github.com/andrej220/HAM/pkg/serverutil"
+	dm "github.com/andrej220/HAM/pkg/shared-models"
+	"github.com/google/uuid"
+	"github.com/segmentio/kafka-go"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	defaultHostsDir        = "/etc/ham/hosts"
+	defaultHostsCollection = "hosts"
+	defaultDataserviceURL  = "http://localhost:8082/dataservice"
+	defaultMaxBatchHosts   = 1000
+)
+
+var (
+	errNoHosts       = errors.New("no hosts match the batch request")
+	errTooManyHosts  = errors.New("batch matches too many hosts")
+	errRegisterBatch = errors.New("failed to register batch")
+	errPublish       = errors.New("failed to publish batch")
+)
+
+// BatchHandler fans a batch request out into one Kafka message per host,
+// all carrying the same batch ID.
+type BatchHandler struct {
+	*Handler
+	hosts          repository.HostSelector
+	dataserviceURL string
+	httpClient     *http.Client
+	maxHosts       int
+}
+
+// newBatchHandler builds a BatchHandler publishing through h.
+func newBatchHandler(cfg DatacollectorProducerConfig, h *Handler, mdb *mongo.Client) (*BatchHandler, error) {
+	hosts, err := newHostSelector(cfg, mdb)
+	if err != nil {
+		return nil, err
+	}
+	b := &BatchHandler{
+		Handler:        h,
+		hosts:          hosts,
+		dataserviceURL: orDefault(cfg.Dataservice.URL, defaultDataserviceURL),
+		httpClient:     &http.Client{Timeout: 10 * time.Second},
+		maxHosts:       cfg.Batch.MaxHosts,
+	}
+	if b.maxHosts <= 0 {
+		b.maxHosts = defaultMaxBatchHosts
+	}
+	return b, nil
+}
+
+// newHostSelector returns the host repository of the repository section
+// of the configuration.
+func newHostSelector(cfg DatacollectorProducerConfig, mdb *mongo.Client) (repository.HostSelector, error) {
+	rc := cfg.Repository
+	switch rc.Type {
+	case "", "file":
+		return repository.NewFileHostRepository(orDefault(rc.HostsDir, defaultHostsDir)), nil
+	case "mongo":
+		if mdb == nil {
+			return nil, fmt.Errorf("repository %q requires database.mongoURI", rc.Type)
+		}
+		coll := mdb.Database(cfg.Database.DBName).Collection(orDefault(rc.HostsCollection, defaultHostsCollection))
+		return repository.NewMongoHostRepository(coll), nil
+	default:
+		return nil, fmt.Errorf("unknown repository type %q", rc.Type)
+	}
+}
+
+// Register adds the batch endpoint below path.
+func (b *BatchHandler) Register(mux *http.ServeMux, path string) {
+	mux.Handle("POST "+path+"/batch", serverutil.NewValidationHandler[dm.BatchRequest](b, validateBatchRequest))
+}
+
+func validateBatchRequest(req *dm.BatchRequest) error {
+	if req.ScriptID <= 0 {
+		return errors.New("scriptid is required")
+	}
+	if len(req.HostIDs) == 0 && req.Group == "" && len(req.Labels) == 0 {
+		return errors.New("one of hostids, group or labels is required")
+	}
+	return nil
+}
+
+func (b *BatchHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	request, ok := r.Context().Value("request").(dm.BatchRequest)
+	if !ok {
+		http.Error(rw, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	ctx, cancel := context.WithTimeout(lg.Attach(context.Background(), b.lg), MAXTIMEOUT)
+	defer cancel()
+
+	batchID := uuid.New()
+	key := r.Header.Get(idempotencyHeader)
+	if key != "" {
+		replayed, ok := b.reserveBatchKey(ctx, rw, key, batchID, &request)
+		if !ok || replayed {
+			return
+		}
+	}
+	batch, err := b.submit(ctx, batchID, request)
+	if err != nil && key != "" {
+		// nothing was queued, a retry with the same key has to publish
+		b.releaseKey(key)
+	}
+	switch {
+	case err == nil:
+		serverutil.RespondWithJSON(rw, http.StatusAccepted, batch)
+	case errors.Is(err, errNoHosts):
+		serverutil.RespondWithError(rw, http.StatusNotFound, "not_found", err.Error())
+	case errors.Is(err, errTooManyHosts):
+		serverutil.RespondWithError(rw, http.StatusBadRequest, "invalid_request", err.Error())
+	case errors.Is(err, errRegisterBatch):
+		serverutil.RespondWithError(rw, http.StatusServiceUnavailable, "unavailable", "Failed to register batch")
+	case errors.Is(err, errPublish):
+		status, text := publishErrorStatus(err)
+		http.Error(rw, text, status)
+	default:
+		http.Error(rw, "Internal server error", http.StatusInternalServerError)
+	}
+}
+
+// submit resolves the hosts of req, registers the batch with the
+// dataservice and publishes one request per host. It returns an error only
+// if nothing was published.
+func (b *BatchHandler) submit(ctx context.Context, batchID uuid.UUID, req dm.BatchRequest) (*dm.Batch, error) {
+	hostIDs, err := b.resolveHosts(ctx, req)
+	if err != nil {
+		if !errors.Is(err, errNoHosts) {
+			b.lg.Error("Failed to resolve batch hosts", lg.Any("err", err))
+		}
+		return nil, err
+	}
+	if len(hostIDs) > b.maxHosts {
+		return nil, fmt.Errorf("%w: %d hosts, at most %d are allowed", errTooManyHosts, len(hostIDs), b.maxHosts)
+	}
+
+	batch := newBatch(batchID, req.ScriptID, hostIDs)
+	b.lg.Info("Started new batch", lg.Any("BatchID", batch.BatchID), lg.Int("hosts", len(hostIDs)))
+
+	// the dataservice has to know the batch before the first result arrives
+	if err := b.registerBatch(ctx, batch); err != nil {
+		b.lg.Error("Failed to register batch", lg.Any("err", err))
+		return nil, fmt.Errorf("%w: %w", errRegisterBatch, err)
+	}
+
+	for _, e := range batch.Executions {
+		b.createExecution(ctx, execstatus.NewExecution(e.ExecutionUID, e.HostID, batch.ScriptID, batch.BatchID))
+	}
+
+	msgs, err := batchMessages(batch)
+	if err != nil {
+		b.lg.Error("Failed to marshal request:", lg.Any("err", err))
+		return nil, err
+	}
+	if err := b.publish(ctx, msgs...); err != nil {
+		var werrs kafka.WriteErrors
+		if errors.As(err, &werrs) && len(werrs) == len(batch.Executions) {
+			// part of the batch is queued, so it is accepted; the rest
+			// is reported failed in the batch status
+			for i, e := range batch.Executions {
+				if werrs[i] != nil {
+					b.setState(e.ExecutionUID, execstatus.Failed, werrs[i])
+				}
+			}
+			b.lg.Error("Failed to publish part of batch", lg.Any("BatchID", batch.BatchID),
+				lg.Int("failed", werrs.Count()), lg.Int("hosts", len(batch.Executions)))
+			return batch, nil
+		}
+		for _, e := range batch.Executions {
+			b.setState(e.ExecutionUID, execstatus.Failed, err)
+		}
+		return nil, fmt.Errorf("%w: %w", errPublish, err)
+	}
+	return batch, nil
+}
+
+// resolveHosts returns the sorted union of the listed hosts and those
+// matching the group and labels of the request.
+func (b *BatchHandler) resolveHosts(ctx context.Context, req dm.BatchRequest) ([]int, error) {
+	ids := slices.Clone(req.HostIDs)
+	if req.Group != "" || len(req.Labels) > 0 {
+		found, err := b.hosts.FindHosts(ctx, repository.HostQuery{Group: req.Group, Labels: req.Labels})
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, found...)
+	}
+	slices.Sort(ids)
+	ids = slices.Compact(ids)
+	if len(ids) == 0 {
+		return nil, errNoHosts
+	}
+	return ids, nil
+}
+
+// newBatch assigns one execution UID per host.
+func newBatch(batchID uuid.UUID, scriptID int, hostIDs []int) *dm.Batch {
+	batch := &dm.Batch{
+		BatchID:    batchID,
+		ScriptID:   scriptID,
+		Executions: make([]dm.BatchExecution, len(hostIDs)),
+		CreatedAt:  time.Now().UTC(),
+	}
+	for i, id := range hostIDs {
+		batch.Executions[i] = dm.BatchExecution{HostID: id, ExecutionUID: uuid.New()}
+	}
+	return batch
+}
+
+// batchMessages returns one collection request per execution of batch.
+func batchMessages(batch *dm.Batch) ([]kafka.Message, error) {
+	now := time.Now()
+	msgs := make([]kafka.Message, 0, len(batch.Executions))
+	for _, e := range batch.Executions {
+		message, err := json.Marshal(dm.Request{
+			HostID:       e.HostID,
+			ScriptID:     batch.ScriptID,
+			ExecutionUID: e.ExecutionUID,
+			BatchID:      batch.BatchID,
+		})
+		if err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, kafka.Message{Key: e.ExecutionUID[:], Value: message, Time: now})
+	}
+	return msgs, nil
+}
+
+// registerBatch stores the batch manifest in the dataservice, which
+// reports the batch status from it.
+func (b *BatchHandler) registerBatch(ctx context.Context, batch *dm.Batch) error {
+	body, err := json.Marshal(batch)
+	if err != nil {
+		return fmt.Errorf("failed to marshal batch: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, b.dataserviceURL+"/batches", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := b.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request to dataservice: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return fmt.Errorf("dataservice returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// fetchBatch loads a registered batch from the dataservice.
+func (b *BatchHandler) fetchBatch(ctx context.Context, batchID uuid.UUID) (*dm.Batch, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, b.dataserviceURL+"/batches/"+batchID.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := b.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request to dataservice: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("dataservice returned status %d", resp.StatusCode)
+	}
+	var status dm.BatchStatus
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		return nil, fmt.Errorf("failed to decode batch: %w", err)
+	}
+	// answer as the first request did, without the progress
+	for i := range status.Executions {
+		status.Executions[i].Status, status.Executions[i].Error = "", ""
+	}
+	return &status.Batch, nil
+}
+
+func orDefault(v, def string) string {
+	if v == "" {
+		return def
+	}
+	return v
+}
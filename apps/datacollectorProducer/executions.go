@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/andrej220/HAM/pkg/execstatus"
+	"github.com/andrej220/HAM/pkg/lg"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const defaultExecutionsCollection = "executions"
+
+func connectMongo(uri string) (*mongo.Client, error) {
+	if uri == "" {
+		return nil, errors.New("database.mongoURI is required")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to MongoDB: %w", err)
+	}
+	if err := client.Ping(ctx, nil); err != nil {
+		client.Disconnect(context.Background())
+		return nil, fmt.Errorf("failed to ping MongoDB: %w", err)
+	}
+	return client, nil
+}
+
+// newExecutionStore returns the store execution states are recorded in,
+// or execstatus.Discard if tracking is off.
+func newExecutionStore(cfg DatacollectorProducerConfig, mdb *mongo.Client) (execstatus.Store, error) {
+	switch cfg.Executions.Store {
+	case "", "none":
+		return execstatus.Discard, nil
+	case "mongo":
+		if mdb == nil {
+			return nil, fmt.Errorf("execution store %q requires database.mongoURI", cfg.Executions.Store)
+		}
+		coll := mdb.Database(cfg.Database.DBName).Collection(orDefault(cfg.Executions.Collection, defaultExecutionsCollection))
+		return execstatus.NewMongoStore(coll), nil
+	default:
+		return nil, fmt.Errorf("unknown execution store %q", cfg.Executions.Store)
+	}
+}
+
+// createExecution records a queued execution before its request is
+// published. Tracking failures are only logged.
+func (h *Handler) createExecution(ctx context.Context, e *execstatus.Execution) {
+	if err := h.executions.Create(ctx, e); err != nil {
+		h.lg.Warn("Failed to record execution",
+			lg.String("exuid", e.ExecutionUID), lg.Any("err", err))
+	}
+}
+
+// setState records the state of an execution. Tracking failures are only
+// logged.
+func (h *Handler) setState(uid uuid.UUID, state execstatus.State, err error) {
+	var msg string
+	if err != nil {
+		msg = err.Error()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := h.executions.Transition(ctx, uid, state, msg); err != nil {
+		h.lg.Warn("Failed to record execution state",
+			lg.String("exuid", uid.String()), lg.String("state", string(state)), lg.Any("err", err))
+	}
+}
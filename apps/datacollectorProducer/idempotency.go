@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/andrej220/HAM/pkg/idempotency"
+	"github.com/andrej220/HAM/pkg/lg"
+	"github.com/andrej220/HAM/pkg/serverutil"
+	dm "github.com/andrej220/HAM/pkg/shared-models"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	idempotencyHeader            = "Idempotency-Key"
+	maxIdempotencyKeyLen         = 255
+	defaultIdempotencyCollection = "idempotency_keys"
+)
+
+// newIdempotencyStore returns the store Idempotency-Key headers are
+// reserved in.
+func newIdempotencyStore(cfg DatacollectorProducerConfig, mdb *mongo.Client) (idempotency.Store, error) {
+	switch cfg.Idempotency.Store {
+	case "", "memory":
+		return idempotency.NewMemoryStore(), nil
+	case "mongo":
+		if mdb == nil {
+			return nil, fmt.Errorf("idempotency store %q requires database.mongoURI", cfg.Idempotency.Store)
+		}
+		store := idempotency.NewMongoStore(mdb.Database(cfg.Database.DBName).
+			Collection(orDefault(cfg.Idempotency.Collection, defaultIdempotencyCollection)))
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := store.EnsureIndexes(ctx); err != nil {
+			return nil, fmt.Errorf("idempotency indexes: %w", err)
+		}
+		return store, nil
+	default:
+		return nil, fmt.Errorf("unknown idempotency store %q", cfg.Idempotency.Store)
+	}
+}
+
+// reserveKey binds key to the execution of request. If the key was already
+// used for the same request, it answers with that execution and reports
+// replayed. ok is false if it answered with an error.
+func (h *Handler) reserveKey(ctx context.Context, rw http.ResponseWriter, key string, request *dm.Request) (replayed, ok bool) {
+	fingerprint := fmt.Sprintf("%d/%d", request.HostID, request.ScriptID)
+	uid, replayed, ok := h.reserve(ctx, rw, key, fingerprint, request.ExecutionUID)
+	if replayed {
+		h.lg.Info("Replayed execution for idempotency key", lg.Any("UUID", uid))
+		rw.Header().Set("Idempotent-Replayed", "true")
+		serverutil.RespondWithJSON(rw, http.StatusAccepted, dm.Response{ExecutionUID: uid})
+	}
+	return replayed, ok
+}
+
+// reserveBatchKey binds key to the batch of request, like reserveKey. A
+// replay answers with the batch as registered with the dataservice.
+func (b *BatchHandler) reserveBatchKey(ctx context.Context, rw http.ResponseWriter, key string, batchID uuid.UUID, request *dm.BatchRequest) (replayed, ok bool) {
+	fingerprint, err := batchFingerprint(request)
+	if err != nil {
+		http.Error(rw, "Internal server error", http.StatusInternalServerError)
+		return false, false
+	}
+	id, replayed, ok := b.reserve(ctx, rw, key, fingerprint, batchID)
+	if replayed {
+		b.lg.Info("Replayed batch for idempotency key", lg.Any("BatchID", id))
+		batch, err := b.fetchBatch(ctx, id)
+		if err != nil {
+			// e.g. the first request is still being registered
+			b.lg.Warn("Failed to load replayed batch", lg.Any("BatchID", id), lg.Any("err", err))
+			batch = &dm.Batch{BatchID: id, ScriptID: request.ScriptID}
+		}
+		rw.Header().Set("Idempotent-Replayed", "true")
+		serverutil.RespondWithJSON(rw, http.StatusAccepted, batch)
+	}
+	return replayed, ok
+}
+
+// reserve binds key to uid, the execution or batch of a request with
+// fingerprint, and returns the uid the key is bound to. replayed is set if
+// that is another one; ok is false if it answered with an error.
+func (h *Handler) reserve(ctx context.Context, rw http.ResponseWriter, key, fingerprint string, uid uuid.UUID) (bound uuid.UUID, replayed, ok bool) {
+	if len(key) > maxIdempotencyKeyLen {
+		serverutil.RespondWithError(rw, http.StatusBadRequest, "invalid_request",
+			fmt.Sprintf("%s is longer than %d characters", idempotencyHeader, maxIdempotencyKeyLen))
+		return uuid.Nil, false, false
+	}
+	bound, created, err := h.idempotency.Reserve(ctx, key, fingerprint, uid, h.idempotencyTTL)
+	switch {
+	case errors.Is(err, idempotency.ErrMismatch):
+		serverutil.RespondWithError(rw, http.StatusUnprocessableEntity, "invalid_request", err.Error())
+		return uuid.Nil, false, false
+	case err != nil:
+		h.lg.Error("Failed to reserve idempotency key", lg.Any("err", err))
+		http.Error(rw, "Service temporarily unavailable", http.StatusServiceUnavailable)
+		return uuid.Nil, false, false
+	}
+	return bound, !created, true
+}
+
+// batchFingerprint identifies a batch request independent of the order of
+// its hosts. It never equals the fingerprint of a single request.
+func batchFingerprint(req *dm.BatchRequest) (string, error) {
+	hostIDs := slices.Clone(req.HostIDs)
+	slices.Sort(hostIDs)
+	hostIDs = slices.Compact(hostIDs)
+	// maps marshal with sorted keys
+	data, err := json.Marshal(dm.BatchRequest{ScriptID: req.ScriptID, HostIDs: hostIDs, Group: req.Group, Labels: req.Labels})
+	if err != nil {
+		return "", err
+	}
+	return "batch:" + string(data), nil
+}
+
+// releaseKey frees a key whose request was not queued.
+func (h *Handler) releaseKey(key string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := h.idempotency.Release(ctx, key); err != nil {
+		h.lg.Warn("Failed to release idempotency key", lg.Any("err", err))
+	}
+}
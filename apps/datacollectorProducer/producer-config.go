@@ -1,5 +1,7 @@
 package main
 
+import "time"
+
 const SERVICENAME = "datacollectorProducer"
 const CONFIGFILENAME = "config.yaml"
 const PROJECTNAME = "HAM"
@@ -15,6 +17,54 @@ type DatacollectorProducerConfig struct{
 		Brokers 	string `yaml:"brokers" json:"brokers"`
 		Topic		string `yaml:"topic" json:"topic"`
 	} `yaml:"kafka" json:"kafka"`
+
+	Database struct {
+		MongoURI string `yaml:"mongoURI" json:"mongoURI"`
+		DBName   string `yaml:"dbName" json:"dbName"`
+	} `yaml:"database" json:"database"`
+
+	// Repository holds the hosts batch requests are resolved against.
+	Repository struct {
+		Type            string `yaml:"type" json:"type"` // file | mongo
+		HostsDir        string `yaml:"hostsDir" json:"hostsDir"`
+		HostsCollection string `yaml:"hostsCollection" json:"hostsCollection"`
+	} `yaml:"repository" json:"repository"`
+
+	// Executions is the store the execution states are recorded in,
+	// shared with the datacollector and the dataservice.
+	Executions struct {
+		Store      string `yaml:"store" json:"store"` // none | mongo
+		Collection string `yaml:"collection" json:"collection"`
+	} `yaml:"executions" json:"executions"`
+
+	// Idempotency holds the Idempotency-Key reservations. The memory
+	// store is per replica; use mongo when running several.
+	Idempotency struct {
+		Store      string        `yaml:"store" json:"store"` // memory | mongo
+		Collection string        `yaml:"collection" json:"collection"`
+		TTL        time.Duration `yaml:"ttl" json:"ttl"`
+	} `yaml:"idempotency" json:"idempotency"`
+
+	// Scheduler fires the stored schedules; it needs MongoDB.
+	Scheduler struct {
+		Enabled    bool          `yaml:"enabled" json:"enabled"`
+		Collection string        `yaml:"collection" json:"collection"`
+		Interval   time.Duration `yaml:"interval" json:"interval"` // how often due schedules are checked
+	} `yaml:"scheduler" json:"scheduler"`
+
+	Dataservice struct {
+		URL string `yaml:"url" json:"url"` // base URL batches are registered at
+	} `yaml:"dataservice" json:"dataservice"`
+
+	Batch struct {
+		MaxHosts int `yaml:"maxHosts" json:"maxHosts"`
+	} `yaml:"batch" json:"batch"`
+}
+
+// needsMongo reports whether any configured store is backed by MongoDB.
+func (c *DatacollectorProducerConfig) needsMongo() bool {
+	return c.Repository.Type == "mongo" || c.Executions.Store == "mongo" || c.Scheduler.Enabled ||
+		c.Idempotency.Store == "mongo"
 }
 
 func NewDatacollectorProducerConfig() DatacollectorProducerConfig{
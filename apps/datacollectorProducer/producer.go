@@ -19,6 +19,9 @@ import(
 	"errors"
 	"github.com/google/uuid"
 	"math/rand"
+	"github.com/andrej220/HAM/pkg/execstatus"
+	"github.com/andrej220/HAM/pkg/idempotency"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 const (
@@ -43,6 +46,9 @@ type  Producer struct{
 type Handler struct{
 	producer 	*Producer
 	lg 			lg.Logger
+	executions	execstatus.Store
+	idempotency	idempotency.Store
+	idempotencyTTL	time.Duration
 }
 
 func newKafkaProducer(logger lg.Logger, cfg DatacollectorProducerConfig) *Producer {
@@ -59,11 +65,17 @@ func newKafkaProducer(logger lg.Logger, cfg DatacollectorProducerConfig) *Produc
 	}
 }
 
-func newProducerHandler(cfg  DatacollectorProducerConfig, lg lg.Logger) http.Handler {
+func newProducerHandler(cfg  DatacollectorProducerConfig, lg lg.Logger, executions execstatus.Store, keys idempotency.Store) *Handler {
 	producer := newKafkaProducer(lg, cfg)
 	handler := &Handler{
 		producer: producer,
 		lg:       lg,
+		executions: executions,
+		idempotency: keys,
+		idempotencyTTL: cfg.Idempotency.TTL,
+	}
+	if handler.idempotencyTTL <= 0 {
+		handler.idempotencyTTL = idempotency.DefaultTTL
 	}
 	lg.Info("Created handler with Kafka producer")
 	return handler
@@ -80,6 +92,13 @@ func (h *Handler) ServeHTTP(rw http.ResponseWriter, r *http.Request){
 	defer cancel()
 	// set new UUID to the request
 	request.ExecutionUID = uuid.New()
+	key := r.Header.Get(idempotencyHeader)
+	if key != "" {
+		replayed, ok := h.reserveKey(ctx, rw, key, &request)
+		if !ok || replayed {
+			return
+		}
+	}
 	h.lg.Info("Started new execution, %v", lg.Any("UUID", request.ExecutionUID))
 	message, err := json.Marshal(request)
 	if err != nil {
@@ -88,57 +107,111 @@ func (h *Handler) ServeHTTP(rw http.ResponseWriter, r *http.Request){
 		return
 	}
 	//h.lg.Info("preparing to send message")
-	
+
 	msg := kafka.Message{
 		Key:   request.ExecutionUID[:],
 		Value: message,
 		Time:  time.Now(),
 	}
+	h.createExecution(ctx, execstatus.NewExecution(request.ExecutionUID, request.HostID, request.ScriptID, uuid.Nil))
+	if err := h.publish(ctx, msg); err != nil {
+		h.setState(request.ExecutionUID, execstatus.Failed, err)
+		if key != "" {
+			// nothing was queued, a retry with the same key has to publish
+			h.releaseKey(key)
+		}
+		status, text := publishErrorStatus(err)
+		http.Error(rw, text, status)
+		return
+	}
 
+	serverutil.RespondWithJSON(rw, http.StatusAccepted, dm.Response{ExecutionUID: request.ExecutionUID})
+}
+
+// publish writes msgs to Kafka, retrying transient broker errors with
+// backoff. Only the messages that were not written are retried. If some
+// of several messages could not be written in the end, the error is a
+// kafka.WriteErrors with one entry per message of msgs.
+func (h *Handler) publish(ctx context.Context, msgs ...kafka.Message) error {
 	var lastErr error
 	start := time.Now()
+	failed := make(kafka.WriteErrors, len(msgs))
+	pending := make([]int, len(msgs)) // indexes into msgs still to write
+	for i := range pending {
+		pending[i] = i
+	}
 
 	for attempt := 1; attempt <= maxAttempts; attempt++ {
-		if err := h.producer.writer.WriteMessages(ctx, msg); err != nil {
-			lastErr = err
-			if !isTransientKafkaErr(err) || attempt == maxAttempts || ctx.Err() != nil {
-				break
+		batch := make([]kafka.Message, len(pending))
+		for i, j := range pending {
+			batch[i] = msgs[j]
+		}
+		err := h.producer.writer.WriteMessages(ctx, batch...)
+		if err == nil {
+			lastErr = nil
+			for _, j := range pending {
+				failed[j] = nil
+			}
+			break
+		}
+		lastErr = err
+		var werrs kafka.WriteErrors
+		if errors.As(err, &werrs) && len(werrs) == len(pending) {
+			next := pending[:0]
+			for i, j := range pending {
+				failed[j] = werrs[i]
+				if werrs[i] != nil {
+					lastErr = werrs[i]
+					next = append(next, j)
+				}
 			}
-			// backoff + jitter
-			backoff := baseBackoff << (attempt - 1)
-			if backoff > maxBackoff {
-				backoff = maxBackoff
+			pending = next
+		} else {
+			for _, j := range pending {
+				failed[j] = err
 			}
-			jitter := time.Duration(rand.Intn(75)) * time.Millisecond
-			time.Sleep(backoff + jitter)
-			continue
 		}
-		lastErr = nil
-		break
-	}
-	if lastErr != nil {
-		if errors.Is(lastErr, kafka.UnknownTopicOrPartition) {
-			h.lg.Error("kafka topic does not exist",
-				lg.String("action", "create the topic or enable auto-creation"))
-			http.Error(rw, "Failed to process request", http.StatusServiceUnavailable)
-			return
+		if !isTransientKafkaErr(lastErr) || attempt == maxAttempts || ctx.Err() != nil {
+			break
 		}
-		// other broker/timeout errors as transient (503)
-		if isTransientKafkaErr(lastErr) || errors.Is(lastErr, context.DeadlineExceeded) || errors.Is(lastErr, context.Canceled) {
-			h.lg.Info("transient kafka/write error",
-				lg.Any("err", lastErr), lg.Any("latency", time.Since(start)))
-			http.Error(rw, "Service temporarily unavailable", http.StatusServiceUnavailable)
-			return
+		// backoff + jitter
+		backoff := baseBackoff << (attempt - 1)
+		if backoff > maxBackoff {
+			backoff = maxBackoff
 		}
-
+		jitter := time.Duration(rand.Intn(75)) * time.Millisecond
+		time.Sleep(backoff + jitter)
+	}
+	if lastErr == nil {
+		return nil
+	}
+	if errors.Is(lastErr, kafka.UnknownTopicOrPartition) {
+		h.lg.Error("kafka topic does not exist",
+			lg.String("action", "create the topic or enable auto-creation"))
+	} else if isTransientKafkaErr(lastErr) {
+		h.lg.Info("transient kafka/write error",
+			lg.Any("err", lastErr), lg.Any("latency", time.Since(start)))
+	} else {
 		h.lg.Error("permanent write error",
 			lg.Any("err", lastErr), lg.Any("latency", time.Since(start)))
-		http.Error(rw, "Internal server error", http.StatusInternalServerError)
-		return
 	}
+	if n := failed.Count(); n > 0 && n < len(msgs) {
+		return failed
+	}
+	return lastErr
+}
 
-	rw.WriteHeader(http.StatusAccepted)
-	_, _ = rw.Write([]byte("Request accepted and queued\n"))
+// publishErrorStatus maps a publish error to the HTTP status and message
+// returned to the client.
+func publishErrorStatus(err error) (int, string) {
+	if errors.Is(err, kafka.UnknownTopicOrPartition) {
+		return http.StatusServiceUnavailable, "Failed to process request"
+	}
+	// other broker/timeout errors as transient (503)
+	if isTransientKafkaErr(err) {
+		return http.StatusServiceUnavailable, "Service temporarily unavailable"
+	}
+	return http.StatusInternalServerError, "Internal server error"
 }
 
 func isTransientKafkaErr(err error) bool {
@@ -184,10 +257,49 @@ func main(){
 			lg.String("Service name:",cfg.Service.Name), 
 			lg.String("Port:", cfg.Service.Port))
 
+	var mdb *mongo.Client
+	if cfg.needsMongo() {
+		mdb, err = connectMongo(cfg.Database.MongoURI)
+		if err != nil {
+			logger.Error("MongoDB connection failed", lg.Any("err", err))
+			os.Exit(1)
+		}
+		defer mdb.Disconnect(context.Background())
+	}
+	executions, err := newExecutionStore(*cfg, mdb)
+	if err != nil {
+		logger.Error("Execution store setup failed", lg.Any("err", err))
+		os.Exit(1)
+	}
+	keys, err := newIdempotencyStore(*cfg, mdb)
+	if err != nil {
+		logger.Error("Idempotency store setup failed", lg.Any("err", err))
+		os.Exit(1)
+	}
+
 	mux := http.NewServeMux()
-	handler := newProducerHandler(*cfg, logger)
+	handler := newProducerHandler(*cfg, logger, executions, keys)
 	mux.Handle(cfg.Service.HTTPpath, serverutil.NewValidationHandler[dm.Request](handler))
 
+	batchHandler, err := newBatchHandler(*cfg, handler, mdb)
+	if err != nil {
+		logger.Error("Batch handler setup failed", lg.Any("err", err))
+		os.Exit(1)
+	}
+	batchHandler.Register(mux, cfg.Service.HTTPpath)
+
+	schedules, err := newScheduleHandler(*cfg, batchHandler, mdb)
+	if err != nil {
+		logger.Error("Scheduler setup failed", lg.Any("err", err))
+		os.Exit(1)
+	}
+	if schedules != nil {
+		schedules.Register(mux, cfg.Service.HTTPpath)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go schedules.Run(ctx)
+	}
+
 	serverConfig := serverutil.DefaultServerConfig()
 	serverConfig.Logger = logger
 	serverConfig.Port = cfg.Service.Port 
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/andrej220/HAM/pkg/lg"
+	"github.com/andrej220/HAM/pkg/scheduler"
+	"github.com/andrej220/HAM/pkg/serverutil"
+	dm "github.com/andrej220/HAM/pkg/shared-models"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const defaultSchedulesCollection = "schedules"
+
+// ScheduleHandler serves the schedule CRUD endpoints and fires due
+// schedules as batches.
+type ScheduleHandler struct {
+	*BatchHandler
+	store scheduler.Store
+	sched *scheduler.Scheduler
+}
+
+// newScheduleHandler returns nil if the scheduler is disabled.
+func newScheduleHandler(cfg DatacollectorProducerConfig, b *BatchHandler, mdb *mongo.Client) (*ScheduleHandler, error) {
+	if !cfg.Scheduler.Enabled {
+		return nil, nil
+	}
+	if mdb == nil {
+		return nil, errors.New("scheduler requires database.mongoURI")
+	}
+	store := scheduler.NewMongoStore(mdb.Database(cfg.Database.DBName).
+		Collection(orDefault(cfg.Scheduler.Collection, defaultSchedulesCollection)))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := store.EnsureIndexes(ctx); err != nil {
+		return nil, fmt.Errorf("schedule indexes: %w", err)
+	}
+	h := &ScheduleHandler{BatchHandler: b, store: store}
+	h.sched = scheduler.New(store, h.fire, cfg.Scheduler.Interval)
+	return h, nil
+}
+
+// Register adds the schedule endpoints below path.
+func (h *ScheduleHandler) Register(mux *http.ServeMux, path string) {
+	validate := func(s *scheduler.Schedule) error { return s.Validate() }
+	mux.Handle("POST "+path+"/schedules",
+		serverutil.NewValidationHandler[scheduler.Schedule](http.HandlerFunc(h.createSchedule), validate))
+	mux.HandleFunc("GET "+path+"/schedules", h.listSchedules)
+	mux.HandleFunc("GET "+path+"/schedules/{id}", h.getSchedule)
+	mux.Handle("PUT "+path+"/schedules/{id}",
+		serverutil.NewValidationHandler[scheduler.Schedule](http.HandlerFunc(h.updateSchedule), validate))
+	mux.HandleFunc("DELETE "+path+"/schedules/{id}", h.deleteSchedule)
+}
+
+// Run fires due schedules until ctx is done.
+func (h *ScheduleHandler) Run(ctx context.Context) {
+	h.lg.Info("Starting scheduler")
+	h.sched.Run(ctx)
+}
+
+// fire publishes one firing of s as a batch.
+func (h *ScheduleHandler) fire(ctx context.Context, s *scheduler.Schedule) error {
+	req := dm.BatchRequest{ScriptID: s.ScriptID, Group: s.Group}
+	if s.HostID > 0 {
+		req.HostIDs = []int{s.HostID}
+	}
+	ctx, cancel := context.WithTimeout(lg.Attach(ctx, h.lg), MAXTIMEOUT)
+	defer cancel()
+	batch, err := h.submit(ctx, uuid.New(), req)
+	if err != nil {
+		return err
+	}
+	h.lg.Info("Fired schedule", lg.String("schedule", s.ID), lg.Any("BatchID", batch.BatchID))
+	return nil
+}
+
+func (h *ScheduleHandler) createSchedule(rw http.ResponseWriter, r *http.Request) {
+	s, ok := r.Context().Value("request").(scheduler.Schedule)
+	if !ok {
+		http.Error(rw, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	s.ID = uuid.New().String()
+	s.CreatedAt, s.UpdatedAt = now, now
+	s.LastRun, s.LastError = time.Time{}, ""
+	if err := h.sched.Plan(&s); err != nil {
+		serverutil.RespondWithError(rw, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+	if err := h.store.Create(r.Context(), &s); err != nil {
+		h.lg.Error("Failed to store schedule", lg.Any("err", err))
+		serverutil.RespondWithError(rw, http.StatusInternalServerError, "internal", "Failed to store schedule")
+		return
+	}
+	serverutil.RespondWithJSON(rw, http.StatusCreated, s)
+}
+
+func (h *ScheduleHandler) listSchedules(rw http.ResponseWriter, r *http.Request) {
+	list, err := h.store.List(r.Context())
+	if err != nil {
+		h.lg.Error("Failed to list schedules", lg.Any("err", err))
+		serverutil.RespondWithError(rw, http.StatusInternalServerError, "internal", "Failed to query schedules")
+		return
+	}
+	serverutil.RespondWithJSON(rw, http.StatusOK, list)
+}
+
+func (h *ScheduleHandler) getSchedule(rw http.ResponseWriter, r *http.Request) {
+	s, err := h.store.Get(r.Context(), r.PathValue("id"))
+	if err != nil {
+		h.respondStoreError(rw, err)
+		return
+	}
+	serverutil.RespondWithJSON(rw, http.StatusOK, s)
+}
+
+// updateSchedule replaces the definition of a schedule and plans its next
+// run from now.
+func (h *ScheduleHandler) updateSchedule(rw http.ResponseWriter, r *http.Request) {
+	s, ok := r.Context().Value("request").(scheduler.Schedule)
+	if !ok {
+		http.Error(rw, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	s.ID = r.PathValue("id")
+	s.UpdatedAt = time.Now().UTC().Truncate(time.Second)
+	if err := h.sched.Plan(&s); err != nil {
+		serverutil.RespondWithError(rw, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+	if err := h.store.Update(r.Context(), &s); err != nil {
+		h.respondStoreError(rw, err)
+		return
+	}
+	updated, err := h.store.Get(r.Context(), s.ID)
+	if err != nil {
+		h.respondStoreError(rw, err)
+		return
+	}
+	serverutil.RespondWithJSON(rw, http.StatusOK, updated)
+}
+
+func (h *ScheduleHandler) deleteSchedule(rw http.ResponseWriter, r *http.Request) {
+	if err := h.store.Delete(r.Context(), r.PathValue("id")); err != nil {
+		h.respondStoreError(rw, err)
+		return
+	}
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+func (h *ScheduleHandler) respondStoreError(rw http.ResponseWriter, err error) {
+	if errors.Is(err, scheduler.ErrNotFound) {
+		serverutil.RespondWithError(rw, http.StatusNotFound, "not_found", "Schedule not found")
+		return
+	}
+	h.lg.Error("Schedule store failed", lg.Any("err", err))
+	serverutil.RespondWithError(rw, http.StatusInternalServerError, "internal", "Failed to query schedules")
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/andrej220/HAM/pkg/serverutil"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	deleteModeArchive = "archive"
+	deleteModePurge   = "purge"
+
+	// archiveBatchSize is the number of documents archived per round trip.
+	archiveBatchSize = 500
+)
+
+// Archive is a DataCollection moved out of the live collection.
+type Archive struct {
+	DataCollection `bson:",inline"`
+	ArchivedAt     time.Time `bson:"archivedAt" json:"archivedAt"`
+}
+
+// DeleteResult counts the documents a delete request touched.
+type DeleteResult struct {
+	Mode     string `json:"mode"`
+	Archived int64  `json:"archived"`
+	Deleted  int64  `json:"deleted"`
+}
+
+// RegisterArchiveRoutes adds the history deletion endpoints below the given base path.
+func (h *dataserviceHandler) RegisterArchiveRoutes(mux *http.ServeMux, base string) {
+	mux.HandleFunc("DELETE "+base+"/{customerId}/{deviceId}/history", h.deleteHistory)
+	mux.HandleFunc("DELETE "+base+"/{customerId}/{deviceId}/history/{configUUID}", h.deleteHistoryEntry)
+}
+
+// deleteHistory archives or purges every collection of a device.
+func (h *dataserviceHandler) deleteHistory(rw http.ResponseWriter, r *http.Request) {
+	filter := deviceFilter(r.PathValue("customerId"), r.PathValue("deviceId"))
+	h.deleteMatching(rw, r, filter)
+}
+
+// deleteHistoryEntry archives or purges a single collection of a device.
+func (h *dataserviceHandler) deleteHistoryEntry(rw http.ResponseWriter, r *http.Request) {
+	filter := deviceFilter(r.PathValue("customerId"), r.PathValue("deviceId"))
+	filter["configUUID"] = r.PathValue("configUUID")
+	h.deleteMatching(rw, r, filter)
+}
+
+// deleteMatching runs the delete mode requested by the "mode" query parameter.
+// "archive" (default) moves live documents to the archive collection,
+// "purge" removes matching documents from both collections. It answers with
+// the DeleteResult.
+func (h *dataserviceHandler) deleteMatching(rw http.ResponseWriter, r *http.Request, filter bson.M) {
+	mode := r.URL.Query().Get("mode")
+	if mode == "" {
+		mode = deleteModeArchive
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), queryTimeout)
+	defer cancel()
+
+	var (
+		res DeleteResult
+		err error
+	)
+	switch mode {
+	case deleteModeArchive:
+		res, err = archiveCollections(ctx, h.collection(), h.archiveCollection(), filter)
+	case deleteModePurge:
+		res, err = purgeCollections(ctx, h.collection(), h.archiveCollection(), filter)
+	default:
+		serverutil.RespondWithError(rw, http.StatusBadRequest, "invalid_request",
+			fmt.Sprintf("mode must be %q or %q", deleteModeArchive, deleteModePurge))
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to %s history: %v", mode, err)
+		serverutil.RespondWithError(rw, http.StatusInternalServerError, "internal", "Failed to delete history")
+		return
+	}
+	if res.Archived == 0 && res.Deleted == 0 {
+		serverutil.RespondWithError(rw, http.StatusNotFound, "not_found", "No history found")
+		return
+	}
+	log.Printf("History %s: %d archived, %d deleted", mode, res.Archived, res.Deleted)
+	serverutil.RespondWithJSON(rw, http.StatusOK, res)
+}
+
+// archiveCollections copies matching documents to the archive collection and
+// then removes them from the live one, archiveBatchSize documents at a time.
+// Copies are upserts keyed by _id, so a retried request after a partial
+// failure does not duplicate archive entries.
+func archiveCollections(ctx context.Context, live, archive *mongo.Collection, filter bson.M) (DeleteResult, error) {
+	res := DeleteResult{Mode: deleteModeArchive}
+
+	cursor, err := live.Find(ctx, filter, options.Find().SetBatchSize(archiveBatchSize))
+	if err != nil {
+		return res, fmt.Errorf("find: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	now := time.Now().UTC()
+	ids := make([]string, 0, archiveBatchSize)
+	models := make([]mongo.WriteModel, 0, archiveBatchSize)
+	flush := func() error {
+		if len(ids) == 0 {
+			return nil
+		}
+		bw, err := archive.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+		if err != nil {
+			return fmt.Errorf("archive: %w", err)
+		}
+		res.Archived += bw.UpsertedCount + bw.MatchedCount
+
+		dr, err := live.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": ids}})
+		if err != nil {
+			return fmt.Errorf("delete: %w", err)
+		}
+		res.Deleted += dr.DeletedCount
+		ids, models = ids[:0], models[:0]
+		return nil
+	}
+
+	for cursor.Next(ctx) {
+		var doc DataCollection
+		if err := cursor.Decode(&doc); err != nil {
+			return res, fmt.Errorf("decode: %w", err)
+		}
+		redactCredentials(doc.Output)
+		ids = append(ids, doc.ID)
+		models = append(models, mongo.NewReplaceOneModel().
+			SetFilter(bson.M{"_id": doc.ID}).
+			SetReplacement(Archive{DataCollection: doc, ArchivedAt: now}).
+			SetUpsert(true))
+		if len(ids) == archiveBatchSize {
+			if err := flush(); err != nil {
+				return res, err
+			}
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return res, fmt.Errorf("find: %w", err)
+	}
+	return res, flush()
+}
+
+// purgeCollections removes matching documents from the live and archive collections.
+func purgeCollections(ctx context.Context, live, archive *mongo.Collection, filter bson.M) (DeleteResult, error) {
+	res := DeleteResult{Mode: deleteModePurge}
+
+	for _, coll := range []*mongo.Collection{live, archive} {
+		dr, err := coll.DeleteMany(ctx, filter)
+		if err != nil {
+			return res, fmt.Errorf("delete from %s: %w", coll.Name(), err)
+		}
+		res.Deleted += dr.DeletedCount
+	}
+	return res, nil
+}
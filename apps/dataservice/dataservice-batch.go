@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/andrej220/HAM/pkg/execstatus"
+	gp "github.com/andrej220/HAM/pkg/graphproc"
+	"github.com/andrej220/HAM/pkg/serverutil"
+	dm "github.com/andrej220/HAM/pkg/shared-models"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// BatchDocument is the stored manifest of a batch request.
+type BatchDocument struct {
+	ID         string                   `bson:"_id"`
+	ScriptID   int                      `bson:"scriptId"`
+	Executions []BatchExecutionDocument `bson:"executions"`
+	CreatedAt  time.Time                `bson:"createdAt"`
+}
+
+type BatchExecutionDocument struct {
+	HostID       int    `bson:"hostId"`
+	ExecutionUID string `bson:"exuid"`
+}
+
+// RegisterBatchRoutes adds the batch endpoints below the given base path.
+// The producer registers every batch it fans out; the status endpoint
+// joins the manifest with the stored collections and, for executions
+// without one, the execution states.
+func (h *dataserviceHandler) RegisterBatchRoutes(mux *http.ServeMux, base string) {
+	mux.HandleFunc("POST "+base+"/batches", h.createBatch)
+	mux.HandleFunc("GET "+base+"/batches/{batchId}", h.getBatch)
+}
+
+// batchCollection falls back to "<collection>_batches" when not configured.
+func (h *dataserviceHandler) batchCollection() *mongo.Collection {
+	name := h.dbConf.BatchCollection
+	if name == "" {
+		name = h.dbConf.MongoCollection + "_batches"
+	}
+	return h.mongodbClient.Database(h.dbConf.MongoDBName).Collection(name)
+}
+
+// createBatch stores a batch manifest.
+func (h *dataserviceHandler) createBatch(rw http.ResponseWriter, r *http.Request) {
+	var batch dm.Batch
+	if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+		serverutil.RespondWithError(rw, http.StatusBadRequest, "invalid_request", "Invalid batch: "+err.Error())
+		return
+	}
+	if batch.BatchID == uuid.Nil || len(batch.Executions) == 0 {
+		serverutil.RespondWithError(rw, http.StatusBadRequest, "invalid_request", "batchid and executions are required")
+		return
+	}
+
+	doc := BatchDocument{
+		ID:        batch.BatchID.String(),
+		ScriptID:  batch.ScriptID,
+		CreatedAt: batch.CreatedAt,
+	}
+	for _, e := range batch.Executions {
+		doc.Executions = append(doc.Executions, BatchExecutionDocument{HostID: e.HostID, ExecutionUID: e.ExecutionUID.String()})
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), queryTimeout)
+	defer cancel()
+	_, err := h.batchCollection().InsertOne(ctx, doc)
+	if mongo.IsDuplicateKeyError(err) {
+		serverutil.RespondWithError(rw, http.StatusConflict, "conflict", "Batch already exists")
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to store batch: %v", err)
+		serverutil.RespondWithError(rw, http.StatusInternalServerError, "internal", "Failed to store batch")
+		return
+	}
+	serverutil.RespondWithJSON(rw, http.StatusCreated, nil)
+}
+
+// getBatch returns the batch with the job status of every execution that
+// has a stored result. Executions that failed before delivering one are
+// failed, the others pending.
+func (h *dataserviceHandler) getBatch(rw http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), queryTimeout)
+	defer cancel()
+
+	var doc BatchDocument
+	err := h.batchCollection().FindOne(ctx, bson.M{"_id": r.PathValue("batchId")}).Decode(&doc)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		serverutil.RespondWithError(rw, http.StatusNotFound, "not_found", "Batch not found")
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to query batch: %v", err)
+		serverutil.RespondWithError(rw, http.StatusInternalServerError, "internal", "Failed to query data")
+		return
+	}
+
+	uuids := make([]string, len(doc.Executions))
+	for i, e := range doc.Executions {
+		uuids[i] = e.ExecutionUID
+	}
+	statuses, err := h.collectionStatuses(ctx, uuids)
+	if err != nil {
+		log.Printf("Failed to query batch results: %v", err)
+		serverutil.RespondWithError(rw, http.StatusInternalServerError, "internal", "Failed to query data")
+		return
+	}
+	failures, err := h.executionFailures(ctx, doc.Executions, statuses)
+	if err != nil {
+		log.Printf("Failed to query batch executions: %v", err)
+		serverutil.RespondWithError(rw, http.StatusInternalServerError, "internal", "Failed to query data")
+		return
+	}
+	serverutil.RespondWithJSON(rw, http.StatusOK, newBatchStatus(&doc, statuses, failures))
+}
+
+// executionFailures returns the errors of the executions without a stored
+// result that are failed in the execution store.
+func (h *dataserviceHandler) executionFailures(ctx context.Context, executions []BatchExecutionDocument, statuses map[string]string) (map[string]string, error) {
+	var open []uuid.UUID
+	for _, e := range executions {
+		if statuses[e.ExecutionUID] != "" {
+			continue
+		}
+		if uid, err := uuid.Parse(e.ExecutionUID); err == nil {
+			open = append(open, uid)
+		}
+	}
+	if len(open) == 0 {
+		return nil, nil
+	}
+	list, err := h.executions.List(ctx, open)
+	if err != nil {
+		return nil, err
+	}
+	failures := make(map[string]string)
+	for _, e := range list {
+		if e.State == execstatus.Failed {
+			failures[e.ExecutionUID] = e.Error
+		}
+	}
+	return failures, nil
+}
+
+// collectionStatuses returns the job status of the live collections stored
+// for the given configUUIDs.
+func (h *dataserviceHandler) collectionStatuses(ctx context.Context, uuids []string) (map[string]string, error) {
+	opts := options.Find().SetProjection(bson.M{"configUUID": 1, "status": 1})
+	cursor, err := h.collection().Find(ctx, bson.M{"configUUID": bson.M{"$in": uuids}}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	statuses := make(map[string]string, len(uuids))
+	for cursor.Next(ctx) {
+		var dc struct {
+			ConfigUUID string `bson:"configUUID"`
+			Status     string `bson:"status"`
+		}
+		if err := cursor.Decode(&dc); err != nil {
+			return nil, err
+		}
+		statuses[dc.ConfigUUID] = dc.Status
+	}
+	return statuses, cursor.Err()
+}
+
+// newBatchStatus joins a batch manifest with the statuses of its results
+// and the errors of failed executions without one.
+func newBatchStatus(doc *BatchDocument, statuses, failures map[string]string) *dm.BatchStatus {
+	bs := &dm.BatchStatus{
+		Batch: dm.Batch{
+			ScriptID:   doc.ScriptID,
+			CreatedAt:  doc.CreatedAt,
+			Executions: make([]dm.BatchExecution, 0, len(doc.Executions)),
+		},
+		Counts: make(map[string]int),
+	}
+	bs.BatchID, _ = uuid.Parse(doc.ID)
+	for _, e := range doc.Executions {
+		be := dm.BatchExecution{HostID: e.HostID, Status: statuses[e.ExecutionUID]}
+		be.ExecutionUID, _ = uuid.Parse(e.ExecutionUID)
+		if be.Status == "" {
+			be.Status = dm.BatchPending
+			if msg, failed := failures[e.ExecutionUID]; failed {
+				be.Status, be.Error = string(gp.JobFailed), msg
+			}
+		}
+		bs.Executions = append(bs.Executions, be)
+		bs.Counts[be.Status]++
+	}
+	return bs
+}
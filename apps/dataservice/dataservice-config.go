@@ -7,6 +7,9 @@ const PROJECTNAME = "HAM"
 type DBConfig struct{
 	MongoCollection string	`yaml:"mongoCollection" json:"mongoCollection"`
 	MongoDBName 	string	`yaml:"mongoDBName" json:"mongoDBName"`
+	ArchiveCollection string `yaml:"archiveCollection" json:"archiveCollection"`
+	BatchCollection string `yaml:"batchCollection" json:"batchCollection"`
+	ExecutionCollection string `yaml:"executionCollection" json:"executionCollection"`
 }
 
 type DataserviceConfig struct {
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/andrej220/HAM/pkg/execstatus"
+	"github.com/andrej220/HAM/pkg/serverutil"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// RegisterExecutionRoutes adds the execution status endpoint. The states
+// are written by the producer, the datacollector and this service.
+func (h *dataserviceHandler) RegisterExecutionRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /executions/{uuid}", h.getExecution)
+}
+
+// executionCollection falls back to "executions" when not configured. The
+// other services have to use the same collection.
+func (h *dataserviceHandler) executionCollection() *mongo.Collection {
+	name := h.dbConf.ExecutionCollection
+	if name == "" {
+		name = "executions"
+	}
+	return h.mongodbClient.Database(h.dbConf.MongoDBName).Collection(name)
+}
+
+func (h *dataserviceHandler) getExecution(rw http.ResponseWriter, r *http.Request) {
+	uid, err := uuid.Parse(r.PathValue("uuid"))
+	if err != nil {
+		serverutil.RespondWithError(rw, http.StatusBadRequest, "invalid_request", "Invalid execution UUID")
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), queryTimeout)
+	defer cancel()
+
+	e, err := h.executions.Get(ctx, uid)
+	if errors.Is(err, execstatus.ErrNotFound) {
+		serverutil.RespondWithError(rw, http.StatusNotFound, "not_found", "Execution not found")
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to query execution: %v", err)
+		serverutil.RespondWithError(rw, http.StatusInternalServerError, "internal", "Failed to query data")
+		return
+	}
+	serverutil.RespondWithJSON(rw, http.StatusOK, e)
+}
+
+// setState records the state of an execution. Tracking failures are only
+// logged, they must not fail the request.
+func (h *dataserviceHandler) setState(uid uuid.UUID, state execstatus.State, errMsg string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := h.executions.Transition(ctx, uid, state, errMsg); err != nil {
+		log.Printf("Failed to record execution %s as %s: %v", uid, state, err)
+	}
+}
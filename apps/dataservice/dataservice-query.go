@@ -0,0 +1,315 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	gp "github.com/andrej220/HAM/pkg/graphproc"
+	"github.com/andrej220/HAM/pkg/serverutil"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	defaultLimit = 10
+	maxLimit     = 100
+	queryTimeout = 10 * time.Second
+)
+
+// DataCollection is the document stored for every collected graph.
+// Field names follow the DataCollection schema of the public API.
+type DataCollection struct {
+	ID         string       `bson:"_id,omitempty" json:"-"`
+	CustomerID string       `bson:"customerId" json:"customerId"`
+	DeviceID   string       `bson:"deviceId" json:"deviceId"`
+	ConfigUUID string       `bson:"configUUID" json:"configUUID"`
+	ScriptID   string       `bson:"scriptId" json:"scriptId"`
+	Status     gp.JobStatus `bson:"status,omitempty" json:"status,omitempty"` // complete, partial or failed
+	BatchID    string       `bson:"batchId,omitempty" json:"batchId,omitempty"`
+	Output     *gp.Graph    `bson:"output" json:"output"`
+	// Data holds the node results keyed by node ID (see Graph.Data), so
+	// fields can be queried directly, e.g. data.system.cpu.Architecture.
+	Data       map[string]any `bson:"data,omitempty" json:"data,omitempty"`
+	ExecutedAt time.Time      `bson:"executedAt" json:"executedAt"`
+}
+
+// NewDataCollection wraps a collected graph with the metadata used for lookups.
+// The credentials of the graph are cleared, they are not stored.
+func NewDataCollection(graph *gp.Graph) *DataCollection {
+	redactCredentials(graph)
+	dc := &DataCollection{
+		ConfigUUID: graph.UUID.String(),
+		Output:     graph,
+		Data:       graph.Data(),
+		Status:     graph.Status,
+		BatchID:    graph.BatchID,
+		ExecutedAt: time.Now().UTC(),
+	}
+	if graph.HostCfg != nil {
+		dc.CustomerID = strconv.Itoa(graph.HostCfg.CustomerID)
+		dc.DeviceID = strconv.Itoa(graph.HostCfg.HostID)
+		dc.ScriptID = strconv.Itoa(graph.HostCfg.ScriptID)
+	}
+	return dc
+}
+
+// redactCredentials clears the passwords and keys the collector used to
+// reach the host.
+func redactCredentials(graph *gp.Graph) {
+	if graph == nil {
+		return
+	}
+	if graph.Config != nil {
+		graph.Config.Password = ""
+	}
+	if graph.HostCfg != nil {
+		graph.HostCfg.HostPass = ""
+		graph.HostCfg.HostKey = ""
+	}
+}
+
+// credentialFields are the paths of the fields cleared by redactCredentials
+// in a stored DataCollection.
+var credentialFields = []string{"output.config.password", "output.hostcfg.hostpass", "output.hostcfg.hostkey"}
+
+// redactStoredCredentials clears the credentials of documents stored before
+// they were redacted on write.
+func redactStoredCredentials(collection *mongo.Collection) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	filter := bson.A{}
+	unset := bson.M{}
+	for _, field := range credentialFields {
+		filter = append(filter, bson.M{field: bson.M{"$nin": bson.A{"", nil}}})
+		unset[field] = ""
+	}
+	res, err := collection.UpdateMany(ctx, bson.M{"$or": filter}, bson.M{"$unset": unset})
+	if err != nil {
+		return 0, err
+	}
+	return res.ModifiedCount, nil
+}
+
+// Pagination holds the limit/offset query parameters.
+type Pagination struct {
+	Limit  int64
+	Offset int64
+}
+
+// parsePagination reads limit and offset from the query string,
+// applying the defaults from the API spec.
+func parsePagination(r *http.Request) (Pagination, error) {
+	p := Pagination{Limit: defaultLimit, Offset: 0}
+	q := r.URL.Query()
+
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || limit <= 0 {
+			return p, fmt.Errorf("limit must be a positive integer")
+		}
+		if limit > maxLimit {
+			return p, fmt.Errorf("limit must not exceed %d", maxLimit)
+		}
+		p.Limit = limit
+	}
+	if v := q.Get("offset"); v != "" {
+		offset, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || offset < 0 {
+			return p, fmt.Errorf("offset must be a non-negative integer")
+		}
+		p.Offset = offset
+	}
+	return p, nil
+}
+
+func deviceFilter(customerID, deviceID string) bson.M {
+	return bson.M{"customerId": customerID, "deviceId": deviceID}
+}
+
+// ensureIndexes creates the indexes used by the query handlers.
+func ensureIndexes(collection *mongo.Collection) error {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	_, err := collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "customerId", Value: 1}, {Key: "deviceId", Value: 1}, {Key: "executedAt", Value: -1}}},
+		{Keys: bson.D{{Key: "configUUID", Value: 1}}},
+	})
+	return err
+}
+
+// legacyGraph holds the fields of a graph stored before the DataCollection
+// wrapper that are needed to build one.
+type legacyGraph struct {
+	ID      string         `bson:"_id"`
+	UUID    uuid.UUID      `bson:"uuid"`
+	HostCfg *gp.HostConfig `bson:"hostcfg"`
+}
+
+// migrateLegacyDocuments wraps graphs stored as they were posted, before
+// the DataCollection format, so the query handlers find them. The graph
+// moves to output unchanged. Legacy documents carry no collection time;
+// their executedAt stays zero, which sorts them after all newer ones.
+func migrateLegacyDocuments(collection *mongo.Collection) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	filter := bson.M{"configUUID": bson.M{"$exists": false}, "root": bson.M{"$exists": true}}
+	cursor, err := collection.Find(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	migrated := 0
+	for cursor.Next(ctx) {
+		var graph legacyGraph
+		var output bson.M
+		if err := cursor.Decode(&graph); err != nil {
+			return migrated, err
+		}
+		if err := cursor.Decode(&output); err != nil {
+			return migrated, err
+		}
+		delete(output, "_id")
+		doc := bson.M{
+			"configUUID": graph.UUID.String(),
+			"output":     output,
+			"executedAt": time.Time{},
+		}
+		if graph.HostCfg != nil {
+			doc["customerId"] = strconv.Itoa(graph.HostCfg.CustomerID)
+			doc["deviceId"] = strconv.Itoa(graph.HostCfg.HostID)
+			doc["scriptId"] = strconv.Itoa(graph.HostCfg.ScriptID)
+		}
+		// replace only if it was not migrated meanwhile by another instance
+		replace := bson.M{"_id": graph.ID, "configUUID": bson.M{"$exists": false}}
+		if _, err := collection.ReplaceOne(ctx, replace, doc); err != nil {
+			return migrated, err
+		}
+		migrated++
+	}
+	return migrated, cursor.Err()
+}
+
+// RegisterQueryRoutes adds the read endpoints below the given base path.
+func (h *dataserviceHandler) RegisterQueryRoutes(mux *http.ServeMux, base string) {
+	mux.HandleFunc("GET "+base+"/uuid/{configUUID}", h.getByUUID)
+	mux.HandleFunc("GET "+base+"/{customerId}/{deviceId}", h.getLatest)
+	mux.HandleFunc("GET "+base+"/{customerId}/{deviceId}/history", h.getHistory)
+}
+
+// getLatest returns the most recent collection for a device.
+func (h *dataserviceHandler) getLatest(rw http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), queryTimeout)
+	defer cancel()
+
+	filter := deviceFilter(r.PathValue("customerId"), r.PathValue("deviceId"))
+	opts := options.FindOne().SetSort(bson.D{{Key: "executedAt", Value: -1}})
+
+	var doc DataCollection
+	err := h.collection().FindOne(ctx, filter, opts).Decode(&doc)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		serverutil.RespondWithError(rw, http.StatusNotFound, "not_found", "Output not found")
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to query latest collection: %v", err)
+		serverutil.RespondWithError(rw, http.StatusInternalServerError, "internal", "Failed to query data")
+		return
+	}
+	redactCredentials(doc.Output)
+	serverutil.RespondWithJSON(rw, http.StatusOK, &doc)
+}
+
+// getHistory returns a page of collections for a device, newest first.
+func (h *dataserviceHandler) getHistory(rw http.ResponseWriter, r *http.Request) {
+	page, err := parsePagination(r)
+	if err != nil {
+		serverutil.RespondWithError(rw, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), queryTimeout)
+	defer cancel()
+
+	filter := deviceFilter(r.PathValue("customerId"), r.PathValue("deviceId"))
+	opts := options.Find().
+		SetSort(bson.D{{Key: "executedAt", Value: -1}}).
+		SetSkip(page.Offset).
+		SetLimit(page.Limit)
+
+	docs, err := findCollections(ctx, h.collection(), filter, opts)
+	if err != nil {
+		log.Printf("Failed to query collection history: %v", err)
+		serverutil.RespondWithError(rw, http.StatusInternalServerError, "internal", "Failed to query data")
+		return
+	}
+	if len(docs) == 0 {
+		serverutil.RespondWithError(rw, http.StatusNotFound, "not_found", "No history found")
+		return
+	}
+	serverutil.RespondWithJSON(rw, http.StatusOK, docs)
+}
+
+// getByUUID returns the collection stored for a configUUID,
+// looking in the archive when it is no longer live.
+func (h *dataserviceHandler) getByUUID(rw http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), queryTimeout)
+	defer cancel()
+
+	filter := bson.M{"configUUID": r.PathValue("configUUID")}
+
+	var doc DataCollection
+	err := h.collection().FindOne(ctx, filter).Decode(&doc)
+	if err == nil {
+		redactCredentials(doc.Output)
+		serverutil.RespondWithJSON(rw, http.StatusOK, &doc)
+		return
+	}
+	if !errors.Is(err, mongo.ErrNoDocuments) {
+		log.Printf("Failed to query collection by UUID: %v", err)
+		serverutil.RespondWithError(rw, http.StatusInternalServerError, "internal", "Failed to query data")
+		return
+	}
+
+	// Not in the live collection, try the archive.
+	var archived Archive
+	err = h.archiveCollection().FindOne(ctx, filter).Decode(&archived)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		serverutil.RespondWithError(rw, http.StatusNotFound, "not_found", "Output not found")
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to query archive by UUID: %v", err)
+		serverutil.RespondWithError(rw, http.StatusInternalServerError, "internal", "Failed to query data")
+		return
+	}
+	redactCredentials(archived.Output)
+	serverutil.RespondWithJSON(rw, http.StatusOK, &archived)
+}
+
+func findCollections(ctx context.Context, collection *mongo.Collection, filter any, opts *options.FindOptions) ([]DataCollection, error) {
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	docs := make([]DataCollection, 0)
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+	for i := range docs {
+		redactCredentials(docs[i].Output)
+	}
+	return docs, nil
+}
@@ -15,6 +15,7 @@ import (
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"github.com/andrej220/HAM/pkg/config"
+	"github.com/andrej220/HAM/pkg/execstatus"
 )
 
 // TODO: implement api function to initialize MongoDB
@@ -35,13 +36,16 @@ type dataserviceResponse struct {
 type dataserviceHandler struct {
 	mongodbClient *mongo.Client
 	dbConf * DBConfig
+	executions execstatus.Store
 }
 
 func NewDataserviceHandler(mdbClient *mongo.Client, dbconf *DBConfig) *dataserviceHandler {
-	return &dataserviceHandler{
+	h := &dataserviceHandler{
 		mongodbClient: mdbClient,
 		dbConf: dbconf,
 	}
+	h.executions = execstatus.NewMongoStore(h.executionCollection())
+	return h
 }
 
 type DataServiceRequest struct {
@@ -113,22 +117,47 @@ func (h *dataserviceHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request)
 		return
 	}
 	
-	collection := h.mongodbClient.Database(h.dbConf.MongoDBName).Collection(h.dbConf.MongoCollection)
+	if request.HostCfg == nil {
+		http.Error(rw, "Invalid request: hostconfig is required.", http.StatusBadRequest)
+		return
+	}
+
+	collection := h.collection()
 	opt := SaveOptions{
 		Overwrite: true,
 		Prefix:    "",
 		Id:        strconv.Itoa(request.HostCfg.HostID),
 		UUID: 	   request.UUID.String(),
 	}
-	err := SaveToMongo(request, collection, opt)
+	err := SaveToMongo(NewDataCollection(&request), collection, opt)
 	if err != nil {
 		log.Printf("Failed saving to MongoDB %v:", err)
+		h.setState(request.UUID, execstatus.Failed, "store result: "+err.Error())
+		http.Error(rw, "Failed to store result", http.StatusInternalServerError)
+		return
 	}
+	h.setState(request.UUID, execstatus.Stored, "")
+}
 
+func (h *dataserviceHandler) collection() *mongo.Collection {
+	return h.mongodbClient.Database(h.dbConf.MongoDBName).Collection(h.dbConf.MongoCollection)
+}
+
+// archiveCollection falls back to "<collection>_archive" when not configured.
+func (h *dataserviceHandler) archiveCollection() *mongo.Collection {
+	name := h.dbConf.ArchiveCollection
+	if name == "" {
+		name = h.dbConf.MongoCollection + "_archive"
+	}
+	return h.mongodbClient.Database(h.dbConf.MongoDBName).Collection(name)
 }
 
 func dbinitialize(MongoDBURI string) (*mongo.Client, error) {
-	client, err := mongo.Connect(context.Background(), options.Client().ApplyURI(MongoDBURI))
+	// decode embedded documents such as node results into maps, so they
+	// are returned as plain JSON objects
+	opts := options.Client().ApplyURI(MongoDBURI).
+		SetBSONOptions(&options.BSONOptions{DefaultDocumentM: true})
+	client, err := mongo.Connect(context.Background(), opts)
 	if err != nil {
 		log.Fatalf("Failed to connect to MongoDB: %v, check DBURI", err)
 		return nil, err
@@ -180,7 +209,28 @@ func main() {
 	
 	mux := http.NewServeMux()
 	handler := NewDataserviceHandler(mdbClient, &cfg.DB.DBConf)
+	for _, coll := range []*mongo.Collection{handler.collection(), handler.archiveCollection()} {
+		if err := ensureIndexes(coll); err != nil {
+			log.Printf("Failed to create MongoDB indexes on %s: %v", coll.Name(), err)
+		}
+	}
+	if n, err := migrateLegacyDocuments(handler.collection()); err != nil {
+		log.Printf("Failed to migrate legacy documents: %v", err)
+	} else if n > 0 {
+		log.Printf("Migrated %d legacy documents to the DataCollection format", n)
+	}
+	for _, coll := range []*mongo.Collection{handler.collection(), handler.archiveCollection()} {
+		if n, err := redactStoredCredentials(coll); err != nil {
+			log.Printf("Failed to redact credentials on %s: %v", coll.Name(), err)
+		} else if n > 0 {
+			log.Printf("Redacted credentials of %d documents on %s", n, coll.Name())
+		}
+	}
 	mux.Handle(cfg.Server.Endpoint, serverutil.NewValidationHandler[gp.Graph](handler,gp.ValidateGraph))
+	handler.RegisterQueryRoutes(mux, cfg.Server.Endpoint)
+	handler.RegisterArchiveRoutes(mux, cfg.Server.Endpoint)
+	handler.RegisterBatchRoutes(mux, cfg.Server.Endpoint)
+	handler.RegisterExecutionRoutes(mux)
 	config:= serverutil.DefaultServerConfig()
 	config.Port = cfg.Server.Port
 	serverutil.RunServer(mux, config)
@@ -0,0 +1,110 @@
+// Package execstatus tracks the progress of collection executions. The
+// producer, the datacollector and the dataservice each record the states
+// they are responsible for in a shared store.
+package execstatus
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// State is the progress of an execution.
+type State string
+
+const (
+	Queued  State = "queued"  // published to Kafka by the producer
+	Running State = "running" // picked up by a datacollector
+	Sending State = "sending" // graph being delivered to the dataservice
+	Stored  State = "stored"  // result stored by the dataservice
+	Failed  State = "failed"  // collection or delivery failed, see Error
+)
+
+var (
+	ErrNotFound          = errors.New("execution not found")
+	ErrExists            = errors.New("execution already exists")
+	ErrInvalidTransition = errors.New("invalid state transition")
+)
+
+// Transition is one entry of the history of an execution.
+type Transition struct {
+	State State     `bson:"state" json:"state"`
+	At    time.Time `bson:"at" json:"at"`
+	Error string    `bson:"error,omitempty" json:"error,omitempty"`
+}
+
+// Execution is the tracked state of one collection request.
+type Execution struct {
+	ExecutionUID string       `bson:"_id" json:"exuid"`
+	HostID       int          `bson:"hostId,omitempty" json:"hostid,omitempty"`
+	ScriptID     int          `bson:"scriptId,omitempty" json:"scriptid,omitempty"`
+	BatchID      string       `bson:"batchId,omitempty" json:"batchid,omitempty"`
+	State        State        `bson:"state" json:"state"`
+	Error        string       `bson:"error,omitempty" json:"error,omitempty"` // reason of the last failure
+	CreatedAt    time.Time    `bson:"createdAt" json:"createdAt"`
+	UpdatedAt    time.Time    `bson:"updatedAt" json:"updatedAt"`
+	History      []Transition `bson:"history" json:"history"`
+}
+
+// Store records execution states.
+type Store interface {
+	// Create records a new queued execution. It returns ErrExists if the
+	// execution is already known.
+	Create(ctx context.Context, e *Execution) error
+	// Transition moves an execution to state to, recording errMsg for
+	// failures. Executions the store does not know yet, e.g. requests
+	// published without the producer, are created in that state. It
+	// returns ErrInvalidTransition if the current state cannot lead to to.
+	Transition(ctx context.Context, uid uuid.UUID, to State, errMsg string) error
+	Get(ctx context.Context, uid uuid.UUID) (*Execution, error)
+	// List returns the known executions among uids, in no particular
+	// order.
+	List(ctx context.Context, uids []uuid.UUID) ([]*Execution, error)
+}
+
+// transitions lists the states each state can be reached from. Retries
+// move a failed or sending execution back to running, and a redelivered
+// result may be stored again; nothing else leaves stored.
+var transitions = map[State][]State{
+	Running: {Queued, Running, Sending, Failed},
+	Sending: {Running, Sending},
+	Stored:  {Queued, Running, Sending, Failed, Stored},
+	Failed:  {Queued, Running, Sending, Failed},
+}
+
+// CanTransition reports whether an execution in state from may move to to.
+// Queued is only ever the initial state.
+func CanTransition(from, to State) bool {
+	return slices.Contains(transitions[to], from)
+}
+
+// NewExecution returns a queued execution of script on host.
+func NewExecution(uid uuid.UUID, hostID, scriptID int, batchID uuid.UUID) *Execution {
+	e := &Execution{
+		ExecutionUID: uid.String(),
+		HostID:       hostID,
+		ScriptID:     scriptID,
+		State:        Queued,
+	}
+	if batchID != uuid.Nil {
+		e.BatchID = batchID.String()
+	}
+	return e
+}
+
+// Discard is a Store that records nothing, for services running without
+// execution tracking.
+var Discard Store = discard{}
+
+type discard struct{}
+
+func (discard) Create(context.Context, *Execution) error { return nil }
+
+func (discard) Transition(context.Context, uuid.UUID, State, string) error { return nil }
+
+func (discard) Get(context.Context, uuid.UUID) (*Execution, error) { return nil, ErrNotFound }
+
+func (discard) List(context.Context, []uuid.UUID) ([]*Execution, error) { return nil, nil }
@@ -0,0 +1,119 @@
+package execstatus
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestCanTransition(t *testing.T) {
+	tests := []struct {
+		from, to State
+		want     bool
+	}{
+		{Queued, Running, true},
+		{Running, Sending, true},
+		{Sending, Stored, true},
+		{Sending, Running, true},
+		{Failed, Running, true},
+		{Queued, Stored, true},
+		{Stored, Stored, true},
+		{Queued, Sending, false},
+		{Stored, Running, false},
+		{Stored, Failed, false},
+		{Running, Queued, false},
+	}
+	for _, tt := range tests {
+		if got := CanTransition(tt.from, tt.to); got != tt.want {
+			t.Errorf("CanTransition(%s, %s) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestMemoryStoreLifecycle(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+	uid, batch := uuid.New(), uuid.New()
+
+	if err := s.Create(ctx, NewExecution(uid, 1, 2, batch)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Create(ctx, NewExecution(uid, 1, 2, uuid.Nil)); !errors.Is(err, ErrExists) {
+		t.Fatalf("second Create = %v, want ErrExists", err)
+	}
+	for _, st := range []State{Running, Sending, Stored} {
+		if err := s.Transition(ctx, uid, st, ""); err != nil {
+			t.Fatalf("Transition(%s): %v", st, err)
+		}
+	}
+	if err := s.Transition(ctx, uid, Failed, "late"); !errors.Is(err, ErrInvalidTransition) {
+		t.Fatalf("Transition(failed) after stored = %v, want ErrInvalidTransition", err)
+	}
+
+	e, err := s.Get(ctx, uid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.State != Stored || e.HostID != 1 || e.ScriptID != 2 || e.BatchID != batch.String() {
+		t.Errorf("execution = %+v", e)
+	}
+	var states []State
+	for _, h := range e.History {
+		states = append(states, h.State)
+	}
+	if want := []State{Queued, Running, Sending, Stored}; !slices.Equal(states, want) {
+		t.Errorf("history = %v, want %v", states, want)
+	}
+}
+
+func TestMemoryStoreTransitionUnknown(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+	uid := uuid.New()
+
+	if err := s.Transition(ctx, uid, Failed, "dial: refused"); err != nil {
+		t.Fatal(err)
+	}
+	e, err := s.Get(ctx, uid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.State != Failed || e.Error != "dial: refused" {
+		t.Errorf("execution = %+v", e)
+	}
+	// a retry revives it
+	if err := s.Transition(ctx, uid, Running, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get(ctx, uuid.New()); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get unknown = %v, want ErrNotFound", err)
+	}
+}
+
+func TestMemoryStoreList(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+	queued, failed, unknown := uuid.New(), uuid.New(), uuid.New()
+
+	if err := s.Create(ctx, NewExecution(queued, 1, 2, uuid.Nil)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Transition(ctx, failed, Failed, "dial: refused"); err != nil {
+		t.Fatal(err)
+	}
+	list, err := s.List(ctx, []uuid.UUID{queued, failed, unknown})
+	if err != nil {
+		t.Fatal(err)
+	}
+	states := make(map[string]State)
+	for _, e := range list {
+		states[e.ExecutionUID] = e.State
+	}
+	want := map[string]State{queued.String(): Queued, failed.String(): Failed}
+	if len(states) != len(want) || states[queued.String()] != Queued || states[failed.String()] != Failed {
+		t.Errorf("List states = %v, want %v", states, want)
+	}
+}
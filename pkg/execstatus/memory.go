@@ -0,0 +1,78 @@
+package execstatus
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var _ Store = (*MemoryStore)(nil)
+
+// MemoryStore keeps executions in memory. It is not shared between
+// services and is meant for tests and single-process setups.
+type MemoryStore struct {
+	mu         sync.Mutex
+	executions map[string]*Execution
+	now        func() time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{executions: make(map[string]*Execution), now: time.Now}
+}
+
+func (s *MemoryStore) Create(_ context.Context, e *Execution) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.executions[e.ExecutionUID]; ok {
+		return ErrExists
+	}
+	now := s.now().UTC()
+	stored := *e
+	stored.State = Queued
+	stored.CreatedAt, stored.UpdatedAt = now, now
+	stored.History = []Transition{{State: Queued, At: now}}
+	s.executions[e.ExecutionUID] = &stored
+	return nil
+}
+
+func (s *MemoryStore) Transition(_ context.Context, uid uuid.UUID, to State, errMsg string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now().UTC()
+	e, ok := s.executions[uid.String()]
+	if !ok {
+		e = &Execution{ExecutionUID: uid.String(), CreatedAt: now}
+		s.executions[e.ExecutionUID] = e
+	} else if !CanTransition(e.State, to) {
+		return fmt.Errorf("%w: %s to %s", ErrInvalidTransition, e.State, to)
+	}
+	e.State, e.Error, e.UpdatedAt = to, errMsg, now
+	e.History = append(e.History, Transition{State: to, At: now, Error: errMsg})
+	return nil
+}
+
+func (s *MemoryStore) Get(_ context.Context, uid uuid.UUID) (*Execution, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.executions[uid.String()]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	c := *e
+	c.History = append([]Transition(nil), e.History...)
+	return &c, nil
+}
+
+func (s *MemoryStore) List(ctx context.Context, uids []uuid.UUID) ([]*Execution, error) {
+	var list []*Execution
+	for _, uid := range uids {
+		e, err := s.Get(ctx, uid)
+		if err == nil {
+			list = append(list, e)
+		}
+	}
+	return list, nil
+}
@@ -0,0 +1,104 @@
+package execstatus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var _ Store = (*MongoStore)(nil)
+
+// MongoStore keeps one document per execution, keyed by its UUID.
+// Transitions are conditional updates, so concurrent writers cannot move
+// an execution out of a state it may not leave.
+type MongoStore struct {
+	Collection *mongo.Collection
+}
+
+func NewMongoStore(collection *mongo.Collection) *MongoStore {
+	return &MongoStore{Collection: collection}
+}
+
+func (s *MongoStore) Create(ctx context.Context, e *Execution) error {
+	now := time.Now().UTC()
+	doc := *e
+	doc.State = Queued
+	doc.CreatedAt, doc.UpdatedAt = now, now
+	doc.History = []Transition{{State: Queued, At: now}}
+	_, err := s.Collection.InsertOne(ctx, doc)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrExists
+	}
+	if err != nil {
+		return fmt.Errorf("MongoDB InsertOne failed: %w", err)
+	}
+	return nil
+}
+
+func (s *MongoStore) Transition(ctx context.Context, uid uuid.UUID, to State, errMsg string) error {
+	now := time.Now().UTC()
+	t := Transition{State: to, At: now, Error: errMsg}
+	filter := bson.M{"_id": uid.String(), "state": bson.M{"$in": transitions[to]}}
+	update := bson.M{
+		"$set":  bson.M{"state": to, "error": errMsg, "updatedAt": now},
+		"$push": bson.M{"history": t},
+	}
+	res, err := s.Collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return fmt.Errorf("MongoDB UpdateOne failed: %w", err)
+	}
+	if res.MatchedCount > 0 {
+		return nil
+	}
+
+	// either unknown or in a state to cannot follow; the insert tells
+	// them apart
+	_, err = s.Collection.InsertOne(ctx, Execution{
+		ExecutionUID: uid.String(),
+		State:        to,
+		Error:        errMsg,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+		History:      []Transition{t},
+	})
+	if mongo.IsDuplicateKeyError(err) {
+		return fmt.Errorf("%w: to %s", ErrInvalidTransition, to)
+	}
+	if err != nil {
+		return fmt.Errorf("MongoDB InsertOne failed: %w", err)
+	}
+	return nil
+}
+
+func (s *MongoStore) Get(ctx context.Context, uid uuid.UUID) (*Execution, error) {
+	var e Execution
+	err := s.Collection.FindOne(ctx, bson.M{"_id": uid.String()}).Decode(&e)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("MongoDB FindOne failed: %w", err)
+	}
+	return &e, nil
+}
+
+func (s *MongoStore) List(ctx context.Context, uids []uuid.UUID) ([]*Execution, error) {
+	ids := make([]string, len(uids))
+	for i, uid := range uids {
+		ids[i] = uid.String()
+	}
+	cursor, err := s.Collection.Find(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		return nil, fmt.Errorf("MongoDB Find failed: %w", err)
+	}
+	var list []*Execution
+	if err := cursor.All(ctx, &list); err != nil {
+		return nil, fmt.Errorf("MongoDB cursor failed: %w", err)
+	}
+	return list, nil
+}
@@ -0,0 +1,114 @@
+package executor
+
+import (
+    "context"
+    "crypto/rand"
+    "encoding/hex"
+    "fmt"
+    "strings"
+)
+
+// Privilege escalation methods of BecomeExecutor.
+const (
+    BecomeSudo = "sudo"
+    BecomeSu   = "su"
+)
+
+// suPrompt is the password prompt of su. It is not configurable, so only
+// the C/English locale is recognised.
+const suPrompt = "Password: "
+
+// redacted replaces the password in captured output.
+const redacted = "********"
+
+// Become describes how to run scripts as another user.
+type Become struct {
+    Method   string // sudo (default) or su
+    User     string // root if empty
+    Password string // answered when prompted; sudo without a password needs none
+    PTY      bool   // run sudo on a pseudo-terminal, for requiretty; su always gets one
+}
+
+// BecomeExecutor runs scripts as another user through an executor that
+// can answer prompts. The password never appears in the command line and
+// is replaced in the output.
+type BecomeExecutor struct {
+    inner  Executor
+    become Become
+}
+
+// NewBecomeExecutor wraps inner.
+func NewBecomeExecutor(inner Executor, become Become) (*BecomeExecutor, error) {
+    switch become.Method {
+    case "":
+        become.Method = BecomeSudo
+    case BecomeSudo, BecomeSu:
+    default:
+        return nil, fmt.Errorf("unknown become method %q", become.Method)
+    }
+    if become.User == "" {
+        become.User = "root"
+    }
+    return &BecomeExecutor{inner: inner, become: become}, nil
+}
+
+func (e *BecomeExecutor) Run(ctx context.Context, script string, limits Limits) (*RunResult, error) {
+    return collect(ctx, e, script, limits)
+}
+
+// Stream runs the script as the target user, answering the password prompt
+// of sudo or su once.
+func (e *BecomeExecutor) Stream(ctx context.Context, script string, limits Limits, fn LineFunc) (*RunResult, error) {
+    pe, ok := e.inner.(PromptExecutor)
+    if !ok {
+        return &RunResult{}, fmt.Errorf("%T cannot answer prompts", e.inner)
+    }
+    command, prompt := e.Command(script, newMarker())
+    return pe.StreamPrompt(ctx, command, limits, prompt, e.redact(fn))
+}
+
+// StreamInput is Stream with input sent on the script's stdin after the
+// password.
+func (e *BecomeExecutor) StreamInput(ctx context.Context, script string, limits Limits, input []byte, fn LineFunc) (*RunResult, error) {
+    pe, ok := e.inner.(PromptExecutor)
+    if !ok {
+        return &RunResult{}, fmt.Errorf("%T cannot answer prompts", e.inner)
+    }
+    command, prompt := e.Command(script, newMarker())
+    prompt.Input = input
+    return pe.StreamPrompt(ctx, command, limits, prompt, e.redact(fn))
+}
+
+// Command returns the command that runs script as the target user and the
+// prompt it shows. marker makes the prompt and the ready line unique to
+// the run so script output cannot fake them.
+func (e *BecomeExecutor) Command(script, marker string) (string, Prompt) {
+    ready := "HAM-BECOME-OK-" + marker
+    inner := "echo " + ready + "; " + script
+    prompt := Prompt{Answer: e.become.Password, Ready: ready, PTY: e.become.PTY}
+    switch e.become.Method {
+    case BecomeSu:
+        prompt.Text, prompt.PTY = suPrompt, true
+        return "su " + shellQuote(e.become.User) + " -c " + shellQuote(inner), prompt
+    default:
+        prompt.Text = "[ham-become-" + marker + "] password:"
+        return "sudo -S -p " + shellQuote(prompt.Text) + " -u " + shellQuote(e.become.User) +
+            " -- /bin/sh -c " + shellQuote(inner), prompt
+    }
+}
+
+// redact wraps fn to replace the password in every line.
+func (e *BecomeExecutor) redact(fn LineFunc) LineFunc {
+    if e.become.Password == "" {
+        return fn
+    }
+    return func(stream Stream, line string) {
+        fn(stream, strings.ReplaceAll(line, e.become.Password, redacted))
+    }
+}
+
+func newMarker() string {
+    b := make([]byte, 8)
+    rand.Read(b)
+    return hex.EncodeToString(b)
+}
@@ -0,0 +1,106 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+// fakeSudo accepts the password "secret" and prompts again otherwise.
+const fakeSudo = `#!/bin/sh
+while [ "$1" != "--" ]; do
+	case "$1" in -p) prompt=$2; shift;; esac
+	shift
+done
+shift
+printf '%s' "$prompt" >&2
+read -r pw
+if [ "$pw" != "secret" ]; then
+	echo "Sorry, try again." >&2
+	printf '%s' "$prompt" >&2
+	read -r pw
+	exit 1
+fi
+exec "$@"
+`
+
+func fakeSudoExecutor(t *testing.T) *LocalExecutor {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "sudo"), []byte(fakeSudo), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	e := NewLocalExecutor()
+	e.Env = []string{"PATH=" + dir + ":" + os.Getenv("PATH")}
+	return e
+}
+
+func TestBecomeExecutorSudo(t *testing.T) {
+	inner := fakeSudoExecutor(t)
+	e, err := NewBecomeExecutor(inner, Become{Password: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := e.Run(context.Background(), "echo one; echo pw=secret; echo warn >&2; cat", Limits{})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if want := []string{"one", "pw=" + redacted}; !reflect.DeepEqual(res.Stdout, want) {
+		t.Errorf("stdout = %q, want %q", res.Stdout, want)
+	}
+	if want := []string{"warn"}; !reflect.DeepEqual(res.Stderr, want) {
+		t.Errorf("stderr = %q, want %q", res.Stderr, want)
+	}
+
+	e, _ = NewBecomeExecutor(inner, Become{Password: "wrong"})
+	_, err = e.Run(context.Background(), "echo one", Limits{})
+	if !errors.Is(err, ErrPromptRejected) {
+		t.Errorf("wrong password: err = %v", err)
+	}
+}
+
+func TestBecomeExecutorSuNeedsPTY(t *testing.T) {
+	e, _ := NewBecomeExecutor(NewLocalExecutor(), Become{Method: BecomeSu, User: "admin"})
+	if _, err := e.Run(context.Background(), "id", Limits{}); err == nil {
+		t.Fatal("expected an error for su without a pseudo-terminal")
+	}
+	cmd, prompt := e.Command("id -u", "abc")
+	if cmd != `su 'admin' -c 'echo HAM-BECOME-OK-abc; id -u'` || !prompt.PTY || prompt.Text != suPrompt {
+		t.Errorf("command %q, prompt %+v", cmd, prompt)
+	}
+	if _, err := NewBecomeExecutor(NewLocalExecutor(), Become{Method: "doas"}); err == nil {
+		t.Error("expected an error for an unknown method")
+	}
+}
+
+type nopWriteCloser struct {
+	strings.Builder
+	closed bool
+}
+
+func (w *nopWriteCloser) Close() error {
+	w.closed = true
+	return nil
+}
+
+func TestPromptReaderSplitReads(t *testing.T) {
+	stdin := &nopWriteCloser{}
+	p := newPrompter(Prompt{Text: "Password: ", Answer: "pw", Ready: "READY"}, stdin, func() {})
+	out := "motd\nPassword: \r\nREADY\r\nresult Password: \n"
+	data, err := io.ReadAll(p.reader(iotest.OneByteReader(strings.NewReader(out))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(data), "motd\nresult Password: \n"; got != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+	if stdin.String() != "pw\n" || !stdin.closed || p.Err() != nil {
+		t.Errorf("stdin %q, closed %v, err %v", stdin.String(), stdin.closed, p.Err())
+	}
+}
@@ -0,0 +1,108 @@
+package executor
+
+import (
+    "context"
+    "fmt"
+    "strconv"
+    "strings"
+)
+
+// Container runtimes supported by ContainerExecutor.
+const (
+    RuntimeDocker  = "docker"
+    RuntimePodman  = "podman"
+    RuntimeCrictl  = "crictl"
+    RuntimeNsenter = "nsenter"
+)
+
+// ContainerExecutor runs scripts inside a container on the host reached by
+// another executor, e.g. through "docker exec" over SSH.
+type ContainerExecutor struct {
+    inner     Executor
+    runtime   string
+    container string
+    shell     string
+}
+
+// NewContainerExecutor wraps inner. For nsenter, container is the PID of
+// the container's init process or the name of a docker container whose
+// PID is looked up on the host. An empty runtime means docker.
+func NewContainerExecutor(inner Executor, runtime, container string) (*ContainerExecutor, error) {
+    if runtime == "" {
+        runtime = RuntimeDocker
+    }
+    switch runtime {
+    case RuntimeDocker, RuntimePodman, RuntimeCrictl, RuntimeNsenter:
+    default:
+        return nil, fmt.Errorf("unknown container runtime %q", runtime)
+    }
+    if container == "" {
+        return nil, fmt.Errorf("container name is required")
+    }
+    return &ContainerExecutor{inner: inner, runtime: runtime, container: container, shell: "/bin/sh"}, nil
+}
+
+// Command returns the host command that runs script in the container.
+func (e *ContainerExecutor) Command(script string) string {
+    inContainer := e.shell + " -c " + shellQuote(script)
+    switch e.runtime {
+    case RuntimeNsenter:
+        target := e.container
+        if _, err := strconv.Atoi(target); err != nil {
+            target = "\"$(docker inspect -f '{{.State.Pid}}' " + shellQuote(target) + ")\""
+        }
+        return "nsenter -t " + target + " -m -u -i -n -p " + inContainer
+    default:
+        // docker, podman and crictl share the exec syntax
+        return e.runtime + " exec -i " + shellQuote(e.container) + " " + inContainer
+    }
+}
+
+func (e *ContainerExecutor) Run(ctx context.Context, script string, limits Limits) (*RunResult, error) {
+    return e.inner.Run(ctx, e.Command(script), limits)
+}
+
+// Stream streams through the inner executor if it supports streaming and
+// falls back to a buffered run otherwise.
+func (e *ContainerExecutor) Stream(ctx context.Context, script string, limits Limits, fn LineFunc) (*RunResult, error) {
+    se, ok := e.inner.(StreamExecutor)
+    if !ok {
+        res, err := e.inner.Run(ctx, e.Command(script), limits)
+        if res != nil {
+            for _, line := range res.Stdout {
+                fn(Stdout, line)
+            }
+            for _, line := range res.Stderr {
+                fn(Stderr, line)
+            }
+            res.Stdout, res.Stderr = nil, nil
+        }
+        return res, err
+    }
+    return se.Stream(ctx, e.Command(script), limits, fn)
+}
+
+// StreamPrompt runs a prompting script in the container, see Prompt. The
+// inner executor has to support prompts.
+func (e *ContainerExecutor) StreamPrompt(ctx context.Context, script string, limits Limits, prompt Prompt, fn LineFunc) (*RunResult, error) {
+    pe, ok := e.inner.(PromptExecutor)
+    if !ok {
+        return &RunResult{}, fmt.Errorf("%T cannot answer prompts", e.inner)
+    }
+    return pe.StreamPrompt(ctx, e.Command(script), limits, prompt, fn)
+}
+
+// StreamInput runs the script in the container with input on its stdin.
+// The inner executor has to support input.
+func (e *ContainerExecutor) StreamInput(ctx context.Context, script string, limits Limits, input []byte, fn LineFunc) (*RunResult, error) {
+    ie, ok := e.inner.(InputExecutor)
+    if !ok {
+        return &RunResult{}, fmt.Errorf("%T cannot send input", e.inner)
+    }
+    return ie.StreamInput(ctx, e.Command(script), limits, input, fn)
+}
+
+// shellQuote wraps s in single quotes for POSIX shells.
+func shellQuote(s string) string {
+    return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,66 @@
+package executor
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+// recordingExecutor records the command it is asked to run.
+type recordingExecutor struct {
+	fakeExecutor
+	script string
+}
+
+func (r *recordingExecutor) Run(ctx context.Context, script string, limits Limits) (*RunResult, error) {
+	r.script = script
+	return r.fakeExecutor.Run(ctx, script, limits)
+}
+
+func TestContainerExecutorCommand(t *testing.T) {
+	tests := []struct {
+		runtime, container, want string
+	}{
+		{"", "web", `docker exec -i 'web' /bin/sh -c 'cat /etc/os-release'`},
+		{RuntimeCrictl, "3f2a", `crictl exec -i '3f2a' /bin/sh -c 'cat /etc/os-release'`},
+		{RuntimeNsenter, "4242", `nsenter -t 4242 -m -u -i -n -p /bin/sh -c 'cat /etc/os-release'`},
+		{RuntimeNsenter, "web", `nsenter -t "$(docker inspect -f '{{.State.Pid}}' 'web')" -m -u -i -n -p /bin/sh -c 'cat /etc/os-release'`},
+	}
+	for _, tt := range tests {
+		inner := &recordingExecutor{fakeExecutor: fakeExecutor{res: &RunResult{Stdout: []string{"ID=alpine"}}}}
+		e, err := NewContainerExecutor(inner, tt.runtime, tt.container)
+		if err != nil {
+			t.Fatalf("NewContainerExecutor: %v", err)
+		}
+		var lines []string
+		if _, err := e.Stream(context.Background(), "cat /etc/os-release", Limits{}, func(_ Stream, line string) {
+			lines = append(lines, line)
+		}); err != nil {
+			t.Fatalf("Stream: %v", err)
+		}
+		if inner.script != tt.want {
+			t.Errorf("command = %s, want %s", inner.script, tt.want)
+		}
+		if !reflect.DeepEqual(lines, []string{"ID=alpine"}) {
+			t.Errorf("lines = %v", lines)
+		}
+	}
+
+	if _, err := NewContainerExecutor(&fakeExecutor{}, "lxc", "web"); err == nil {
+		t.Error("expected error for unknown runtime")
+	}
+}
+
+func TestContainerExecutorQuotesScript(t *testing.T) {
+	// run the generated command through a fake runtime on the local shell
+	local := NewLocalExecutor()
+	e, _ := NewContainerExecutor(local, RuntimeDocker, "web")
+	script := `printf '%s\n' "it's $((1+1))"`
+	res, err := local.Run(context.Background(), "docker() { shift 3; \"$@\"; }; "+e.Command(script), Limits{})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if !reflect.DeepEqual(res.Stdout, []string{"it's 2"}) {
+		t.Errorf("stdout = %v", res.Stdout)
+	}
+}
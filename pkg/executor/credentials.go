@@ -0,0 +1,234 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// Names under which the built-in providers are registered.
+const (
+	CredentialSourceFile  = "file"
+	CredentialSourceEnv   = "env"
+	CredentialSourceAgent = "agent"
+	CredentialSourceVault = "vault"
+)
+
+var ErrCredentialNotFound = errors.New("credential not found")
+
+// Credential is the authentication material resolved for one host.
+// Close must be called once the SSH handshake is done; agent backed
+// credentials keep the agent connection open until then.
+type Credential struct {
+	User     string
+	Password string
+	Signers  []ssh.Signer
+	closers  []io.Closer
+}
+
+// AuthMethods returns the ssh.AuthMethods for the credential, keys first.
+func (c *Credential) AuthMethods() []ssh.AuthMethod {
+	var methods []ssh.AuthMethod
+	if len(c.Signers) > 0 {
+		methods = append(methods, ssh.PublicKeys(c.Signers...))
+	}
+	if c.Password != "" {
+		methods = append(methods, ssh.Password(c.Password))
+	}
+	return methods
+}
+
+func (c *Credential) Close() error {
+	var errs []error
+	for _, cl := range c.closers {
+		errs = append(errs, cl.Close())
+	}
+	c.closers = nil
+	return errors.Join(errs...)
+}
+
+// CredentialProvider resolves a reference (key name, vault entry, ...) to a Credential.
+type CredentialProvider interface {
+	Credential(ctx context.Context, ref string) (*Credential, error)
+}
+
+// CredentialRegistry selects a CredentialProvider by source name.
+type CredentialRegistry struct {
+	mu        sync.RWMutex
+	providers map[string]CredentialProvider
+}
+
+func NewCredentialRegistry() *CredentialRegistry {
+	return &CredentialRegistry{providers: make(map[string]CredentialProvider)}
+}
+
+func (r *CredentialRegistry) Register(source string, p CredentialProvider) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.providers[source] = p
+}
+
+// Resolve asks the provider registered for source to resolve ref.
+func (r *CredentialRegistry) Resolve(ctx context.Context, source, ref string) (*Credential, error) {
+	r.mu.RLock()
+	p, ok := r.providers[source]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("credential source %q not configured", source)
+	}
+	cred, err := p.Credential(ctx, ref)
+	if err != nil {
+		return nil, fmt.Errorf("%s credential %q: %w", source, ref, err)
+	}
+	return cred, nil
+}
+
+// KeyFileProvider loads private keys from a directory. The reference is a
+// file name inside Dir; an empty reference loads every id_* key in Dir.
+type KeyFileProvider struct {
+	Dir string
+}
+
+func NewKeyFileProvider(dir string) *KeyFileProvider {
+	return &KeyFileProvider{Dir: dir}
+}
+
+func (p *KeyFileProvider) Credential(_ context.Context, ref string) (*Credential, error) {
+	var paths []string
+	if ref != "" {
+		if filepath.IsAbs(ref) || strings.Contains(ref, "..") {
+			return nil, fmt.Errorf("key reference must be a file name inside %s", p.Dir)
+		}
+		paths = []string{filepath.Join(p.Dir, ref)}
+	} else {
+		matches, err := filepath.Glob(filepath.Join(p.Dir, "id_*"))
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range matches {
+			if !strings.HasSuffix(m, ".pub") {
+				paths = append(paths, m)
+			}
+		}
+	}
+
+	cred := &Credential{}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, ErrCredentialNotFound
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to read private key: %w", err)
+		}
+		signer, err := ssh.ParsePrivateKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse private key %s: %w", filepath.Base(path), err)
+		}
+		cred.Signers = append(cred.Signers, signer)
+	}
+	if len(cred.Signers) == 0 {
+		return nil, ErrCredentialNotFound
+	}
+	return cred, nil
+}
+
+// EnvProvider reads credentials from environment variables named
+// <Prefix><REF>_USER, _PASSWORD, _KEY (PEM) and _KEY_PASSPHRASE.
+type EnvProvider struct {
+	Prefix string
+}
+
+func NewEnvProvider(prefix string) *EnvProvider {
+	return &EnvProvider{Prefix: prefix}
+}
+
+func (p *EnvProvider) Credential(_ context.Context, ref string) (*Credential, error) {
+	name := p.Prefix + strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(ref))
+	if ref != "" {
+		name += "_"
+	}
+
+	cred := &Credential{
+		User:     os.Getenv(name + "USER"),
+		Password: os.Getenv(name + "PASSWORD"),
+	}
+	if pem := os.Getenv(name + "KEY"); pem != "" {
+		signer, err := parsePrivateKey([]byte(pem), os.Getenv(name+"KEY_PASSPHRASE"))
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse private key from %sKEY: %w", name, err)
+		}
+		cred.Signers = append(cred.Signers, signer)
+	}
+	if cred.Password == "" && len(cred.Signers) == 0 {
+		return nil, ErrCredentialNotFound
+	}
+	return cred, nil
+}
+
+// AgentProvider uses the keys held by an ssh-agent. The reference, if set,
+// selects keys whose comment contains it.
+type AgentProvider struct {
+	Socket string
+}
+
+// NewAgentProvider falls back to $SSH_AUTH_SOCK when socket is empty.
+func NewAgentProvider(socket string) *AgentProvider {
+	if socket == "" {
+		socket = os.Getenv("SSH_AUTH_SOCK")
+	}
+	return &AgentProvider{Socket: socket}
+}
+
+func (p *AgentProvider) Credential(ctx context.Context, ref string) (*Credential, error) {
+	if p.Socket == "" {
+		return nil, fmt.Errorf("ssh-agent socket is not set")
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "unix", p.Socket)
+	if err != nil {
+		return nil, fmt.Errorf("connect to ssh-agent: %w", err)
+	}
+
+	client := agent.NewClient(conn)
+	signers, err := client.Signers()
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("list ssh-agent keys: %w", err)
+	}
+	if ref != "" {
+		keys, err := client.List()
+		if err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("list ssh-agent keys: %w", err)
+		}
+		var selected []ssh.Signer
+		for i, k := range keys {
+			if strings.Contains(k.Comment, ref) && i < len(signers) {
+				selected = append(selected, signers[i])
+			}
+		}
+		signers = selected
+	}
+	if len(signers) == 0 {
+		conn.Close()
+		return nil, ErrCredentialNotFound
+	}
+	return &Credential{Signers: signers, closers: []io.Closer{conn}}, nil
+}
+
+func parsePrivateKey(pem []byte, passphrase string) (ssh.Signer, error) {
+	if passphrase != "" {
+		return ssh.ParsePrivateKeyWithPassphrase(pem, []byte(passphrase))
+	}
+	return ssh.ParsePrivateKey(pem)
+}
@@ -0,0 +1,61 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestVaultRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vault.json")
+	entries := map[string]VaultEntry{
+		"web-01": {User: "collector", Password: "s3cret"},
+	}
+	if err := SealVault(path, []byte("passphrase"), entries); err != nil {
+		t.Fatalf("SealVault: %v", err)
+	}
+
+	if _, err := OpenVault(path, []byte("wrong")); err == nil {
+		t.Fatal("OpenVault with wrong passphrase succeeded")
+	}
+
+	p := NewVaultProvider(path, []byte("passphrase"))
+	cred, err := p.Credential(context.Background(), "web-01")
+	if err != nil {
+		t.Fatalf("Credential: %v", err)
+	}
+	if cred.User != "collector" || cred.Password != "s3cret" {
+		t.Errorf("got %+v", cred)
+	}
+	if len(cred.AuthMethods()) != 1 {
+		t.Errorf("expected one auth method, got %d", len(cred.AuthMethods()))
+	}
+
+	if _, err := p.Credential(context.Background(), "missing"); !errors.Is(err, ErrCredentialNotFound) {
+		t.Errorf("missing entry: got %v, want ErrCredentialNotFound", err)
+	}
+}
+
+func TestEnvProvider(t *testing.T) {
+	t.Setenv("HAM_CRED_WEB_01_USER", "ops")
+	t.Setenv("HAM_CRED_WEB_01_PASSWORD", "pw")
+
+	reg := NewCredentialRegistry()
+	reg.Register(CredentialSourceEnv, NewEnvProvider("HAM_CRED_"))
+
+	cred, err := reg.Resolve(context.Background(), CredentialSourceEnv, "web-01")
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if cred.User != "ops" || cred.Password != "pw" {
+		t.Errorf("got %+v", cred)
+	}
+
+	if _, err := reg.Resolve(context.Background(), CredentialSourceEnv, "db-01"); !errors.Is(err, ErrCredentialNotFound) {
+		t.Errorf("unset ref: got %v, want ErrCredentialNotFound", err)
+	}
+	if _, err := reg.Resolve(context.Background(), CredentialSourceVault, "web-01"); err == nil {
+		t.Error("unregistered source resolved")
+	}
+}
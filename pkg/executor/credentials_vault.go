@@ -0,0 +1,177 @@
+package executor
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	vaultVersion = 1
+	vaultKeyLen  = 32
+	vaultSaltLen = 16
+	// scrypt cost parameters, see https://pkg.go.dev/golang.org/x/crypto/scrypt
+	vaultScryptN = 1 << 15
+	vaultScryptR = 8
+	vaultScryptP = 1
+)
+
+// VaultEntry is a single named credential kept in the vault.
+type VaultEntry struct {
+	User       string `json:"user,omitempty"`
+	Password   string `json:"password,omitempty"`
+	PrivateKey string `json:"privateKey,omitempty"` // PEM
+	Passphrase string `json:"passphrase,omitempty"` // for PrivateKey
+}
+
+// vaultFile is the on-disk format: the JSON encoded entries sealed with
+// AES-256-GCM under a key derived from the passphrase with scrypt.
+type vaultFile struct {
+	Version int    `json:"version"`
+	Salt    []byte `json:"salt"`
+	Nonce   []byte `json:"nonce"`
+	Data    []byte `json:"data"`
+}
+
+// SealVault encrypts entries with passphrase and writes them to path.
+func SealVault(path string, passphrase []byte, entries map[string]VaultEntry) error {
+	plain, err := json.Marshal(entries)
+	if err != nil {
+		return fmt.Errorf("marshal vault: %w", err)
+	}
+
+	vf := vaultFile{Version: vaultVersion, Salt: make([]byte, vaultSaltLen)}
+	if _, err := rand.Read(vf.Salt); err != nil {
+		return err
+	}
+	aead, err := vaultCipher(passphrase, vf.Salt)
+	if err != nil {
+		return err
+	}
+	vf.Nonce = make([]byte, aead.NonceSize())
+	if _, err := rand.Read(vf.Nonce); err != nil {
+		return err
+	}
+	vf.Data = aead.Seal(nil, vf.Nonce, plain, nil)
+
+	data, err := json.Marshal(vf)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// OpenVault reads and decrypts the vault at path.
+func OpenVault(path string, passphrase []byte) (map[string]VaultEntry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read vault: %w", err)
+	}
+	var vf vaultFile
+	if err := json.Unmarshal(data, &vf); err != nil {
+		return nil, fmt.Errorf("parse vault: %w", err)
+	}
+	if vf.Version != vaultVersion {
+		return nil, fmt.Errorf("unsupported vault version %d", vf.Version)
+	}
+
+	aead, err := vaultCipher(passphrase, vf.Salt)
+	if err != nil {
+		return nil, err
+	}
+	if len(vf.Nonce) != aead.NonceSize() {
+		return nil, fmt.Errorf("invalid vault nonce")
+	}
+	plain, err := aead.Open(nil, vf.Nonce, vf.Data, nil)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt vault: wrong passphrase or corrupted file")
+	}
+
+	entries := make(map[string]VaultEntry)
+	if err := json.Unmarshal(plain, &entries); err != nil {
+		return nil, fmt.Errorf("parse vault entries: %w", err)
+	}
+	return entries, nil
+}
+
+func vaultCipher(passphrase, salt []byte) (cipher.AEAD, error) {
+	if len(passphrase) == 0 {
+		return nil, fmt.Errorf("vault passphrase is empty")
+	}
+	key, err := scrypt.Key(passphrase, salt, vaultScryptN, vaultScryptR, vaultScryptP, vaultKeyLen)
+	if err != nil {
+		return nil, fmt.Errorf("derive vault key: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// VaultProvider serves credentials from an encrypted vault file. The vault
+// is decrypted on first use and again whenever the file changes.
+type VaultProvider struct {
+	Path       string
+	passphrase []byte
+
+	mu      sync.Mutex
+	modTime time.Time
+	entries map[string]VaultEntry
+}
+
+func NewVaultProvider(path string, passphrase []byte) *VaultProvider {
+	return &VaultProvider{Path: path, passphrase: passphrase}
+}
+
+func (p *VaultProvider) Credential(_ context.Context, ref string) (*Credential, error) {
+	entry, err := p.lookup(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	cred := &Credential{User: entry.User, Password: entry.Password}
+	if entry.PrivateKey != "" {
+		signer, err := parsePrivateKey([]byte(entry.PrivateKey), entry.Passphrase)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse private key: %w", err)
+		}
+		cred.Signers = append(cred.Signers, signer)
+	}
+	return cred, nil
+}
+
+func (p *VaultProvider) lookup(ref string) (VaultEntry, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	info, err := os.Stat(p.Path)
+	if err != nil {
+		return VaultEntry{}, fmt.Errorf("stat vault: %w", err)
+	}
+	if p.entries == nil || !info.ModTime().Equal(p.modTime) {
+		entries, err := OpenVault(p.Path, p.passphrase)
+		if err != nil {
+			return VaultEntry{}, err
+		}
+		p.entries, p.modTime = entries, info.ModTime()
+	}
+
+	entry, ok := p.entries[ref]
+	if !ok {
+		return VaultEntry{}, ErrCredentialNotFound
+	}
+	return entry, nil
+}
@@ -0,0 +1,199 @@
+package executor
+
+import (
+    "bytes"
+    "context"
+    "crypto/md5"
+    "crypto/sha1"
+    "crypto/sha256"
+    "encoding/hex"
+    "fmt"
+    "hash"
+    "io"
+    "os"
+
+    gp "github.com/andrej220/HAM/pkg/graphproc"
+    "github.com/cenkalti/backoff/v4"
+    "github.com/pkg/sftp"
+)
+
+// Fetch downloads a file over SFTP on the executor's connection. Like
+// Stream, only failures before the transfer starts are retried.
+func (e *SSHExecutor) Fetch(ctx context.Context, path string, opts FetchOptions) (*FetchResult, error) {
+    if opts.Timeout > 0 {
+        var cancel context.CancelFunc
+        ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+        defer cancel()
+    }
+    attempts := 0
+    var res *FetchResult
+
+    operation := func() error {
+        attempts++
+        sess, release, err := e.client.NewSession(ctx)
+        if err != nil {
+            return fmt.Errorf("new session: %w", err)
+        }
+        defer release()
+        defer sess.Close()
+
+        stdin, err := sess.StdinPipe()
+        if err != nil {
+            return fmt.Errorf("stdin pipe: %w", err)
+        }
+        stdout, err := sess.StdoutPipe()
+        if err != nil {
+            return fmt.Errorf("stdout pipe: %w", err)
+        }
+        if err := sess.RequestSubsystem("sftp"); err != nil {
+            return fmt.Errorf("sftp subsystem: %w", err)
+        }
+        client, err := sftp.NewClientPipe(stdout, stdin)
+        if err != nil {
+            return fmt.Errorf("sftp: %w", err)
+        }
+        defer client.Close()
+
+        done := make(chan struct{})
+        defer close(done)
+        go func() {
+            select {
+            case <-ctx.Done():
+                client.Close()
+                sess.Close()
+            case <-done:
+            }
+        }()
+
+        f, err := client.Open(path)
+        if err != nil {
+            return backoff.Permanent(fmt.Errorf("open %s: %w", path, err))
+        }
+        defer f.Close()
+        info, err := f.Stat()
+        if err != nil {
+            return backoff.Permanent(fmt.Errorf("stat %s: %w", path, err))
+        }
+        if info.IsDir() {
+            return backoff.Permanent(fmt.Errorf("%s is a directory", path))
+        }
+        res, err = download(f, opts)
+        if err != nil {
+            if ctx.Err() != nil {
+                err = fmt.Errorf("fetch aborted: %w", ctx.Err())
+            }
+            return backoff.Permanent(fmt.Errorf("read %s: %w", path, err))
+        }
+        res.Mode, res.ModTime = info.Mode(), info.ModTime()
+        return nil
+    }
+
+    b := backoff.WithContext(e.client.ResConf.NewBackOff(), ctx)
+    err := backoff.Retry(operation, b)
+    if res == nil {
+        res = &FetchResult{}
+    }
+    res.Attempts = attempts
+    return res, err
+}
+
+// Fetch reads a file on the local machine.
+func (e *LocalExecutor) Fetch(ctx context.Context, path string, opts FetchOptions) (*FetchResult, error) {
+    if opts.Timeout > 0 {
+        var cancel context.CancelFunc
+        ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+        defer cancel()
+    }
+    f, err := os.Open(path)
+    if err != nil {
+        return &FetchResult{Attempts: 1}, err
+    }
+    defer f.Close()
+    info, err := f.Stat()
+    if err != nil {
+        return &FetchResult{Attempts: 1}, err
+    }
+    if info.IsDir() {
+        return &FetchResult{Attempts: 1}, fmt.Errorf("%s is a directory", path)
+    }
+
+    done := make(chan struct{})
+    defer close(done)
+    go func() {
+        select {
+        case <-ctx.Done():
+            f.Close()
+        case <-done:
+        }
+    }()
+    res, err := download(f, opts)
+    if res == nil {
+        res = &FetchResult{}
+    }
+    res.Attempts = 1
+    if err != nil {
+        if ctx.Err() != nil {
+            err = fmt.Errorf("fetch aborted: %w", ctx.Err())
+        }
+        return res, fmt.Errorf("read %s: %w", path, err)
+    }
+    res.Mode, res.ModTime = info.Mode(), info.ModTime()
+    return res, nil
+}
+
+// newChecksum returns the hash for a checksum name of FetchOptions.
+func newChecksum(name string) (hash.Hash, error) {
+    switch name {
+    case "", gp.ChecksumSHA256:
+        return sha256.New(), nil
+    case gp.ChecksumSHA1:
+        return sha1.New(), nil
+    case gp.ChecksumMD5:
+        return md5.New(), nil
+    }
+    return nil, fmt.Errorf("unknown checksum %q", name)
+}
+
+// download reads r to the end. The checksum covers everything read, the
+// content keeps at most opts.MaxOutputBytes.
+func download(r io.Reader, opts FetchOptions) (*FetchResult, error) {
+    h, err := newChecksum(opts.Checksum)
+    if err != nil {
+        return nil, err
+    }
+    content := &capWriter{max: opts.MaxOutputBytes, discard: opts.HashOnly}
+    n, err := io.Copy(io.MultiWriter(h, content), r)
+    res := &FetchResult{Size: n, Truncated: content.truncated}
+    if err != nil {
+        return res, err
+    }
+    res.Checksum = hex.EncodeToString(h.Sum(nil))
+    if !opts.HashOnly {
+        res.Content = content.buf.Bytes()
+    }
+    return res, nil
+}
+
+// capWriter keeps the first max bytes written to it, everything if max is
+// zero, and swallows the rest.
+type capWriter struct {
+    buf       bytes.Buffer
+    max       int64
+    discard   bool
+    truncated bool
+}
+
+func (w *capWriter) Write(p []byte) (int, error) {
+    if w.discard {
+        return len(p), nil
+    }
+    keep := p
+    if w.max > 0 {
+        if rest := w.max - int64(w.buf.Len()); int64(len(p)) > rest {
+            keep = p[:max(rest, 0)]
+            w.truncated = true
+        }
+    }
+    w.buf.Write(keep)
+    return len(p), nil
+}
@@ -0,0 +1,109 @@
+package executor
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	gp "github.com/andrej220/HAM/pkg/graphproc"
+)
+
+func writeTestFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "os-release")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func sha256Hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestSSHExecutorFetch(t *testing.T) {
+	srv := startTestSSHServer(t)
+	pool := NewConnPool(PoolConfig{})
+	defer pool.Close()
+	client, err := pool.Get(context.Background(), srv.Addr, testClientConfig())
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	defer client.Close()
+	e := NewSSHExecutor(client)
+
+	content := "NAME=\"Debian GNU/Linux\"\nVERSION_ID=\"12\"\n"
+	path := writeTestFile(t, content)
+	ctx := context.Background()
+
+	res, err := e.Fetch(ctx, path, FetchOptions{})
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	if string(res.Content) != content || res.Size != int64(len(content)) || res.Checksum != sha256Hex(content) {
+		t.Errorf("content %q, size %d, checksum %s", res.Content, res.Size, res.Checksum)
+	}
+	if res.Mode.Perm() != 0o644 || res.Attempts != 1 {
+		t.Errorf("mode %v, attempts %d", res.Mode, res.Attempts)
+	}
+
+	res, err = e.Fetch(ctx, path, FetchOptions{Limits: Limits{MaxOutputBytes: 4}})
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	if string(res.Content) != "NAME" || !res.Truncated || res.Checksum != sha256Hex(content) {
+		t.Errorf("capped: content %q, truncated %v, checksum %s", res.Content, res.Truncated, res.Checksum)
+	}
+
+	res, err = e.Fetch(ctx, path, FetchOptions{HashOnly: true})
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	if res.Content != nil || res.Checksum != sha256Hex(content) {
+		t.Errorf("hash only: content %q, checksum %s", res.Content, res.Checksum)
+	}
+
+	res, err = e.Fetch(ctx, filepath.Join(t.TempDir(), "missing"), FetchOptions{})
+	if err == nil || res.Attempts != 1 {
+		t.Errorf("missing file: err %v, attempts %d, want a permanent error", err, res.Attempts)
+	}
+}
+
+func TestNodeTaskFetch(t *testing.T) {
+	path := writeTestFile(t, "ID: debian\nVERSION_ID: 12\n")
+	node := &gp.Node{ID: "os", Type: gp.NodeTypeFetch, PostProcess: "key_value",
+		Fetch: &gp.Fetch{Path: path, Checksum: gp.ChecksumMD5}}
+	if err := NewNodeTask(node, NewLocalExecutor()).Execute(context.Background()); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	want := map[string]any{"ID": "debian", "VERSION_ID": "12"}
+	if !reflect.DeepEqual(node.Result, want) || node.Status != gp.StatusOK {
+		t.Errorf("result %v, status %q", node.Result, node.Status)
+	}
+	if node.File == nil || node.File.Size != 26 || !strings.HasPrefix(node.File.Checksum, "md5:") {
+		t.Errorf("file = %+v", node.File)
+	}
+
+	bin := filepath.Join(t.TempDir(), "bin")
+	if err := os.WriteFile(bin, []byte{0xff, 0x00, 0xfe}, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	node = &gp.Node{ID: "bin", Type: gp.NodeTypeFetch, Fetch: &gp.Fetch{Path: bin}}
+	if err := NewNodeTask(node, NewLocalExecutor()).Execute(context.Background()); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if node.Result != "/wD+" || node.File.Encoding != "base64" {
+		t.Errorf("result %v, file %+v", node.Result, node.File)
+	}
+
+	node = &gp.Node{ID: "c", Type: gp.NodeTypeFetch, Fetch: &gp.Fetch{Path: path}}
+	if err := NewNodeTask(node, &fakeExecutor{}).Execute(context.Background()); err == nil || node.Status != gp.StatusFailed {
+		t.Errorf("executor without Fetch: err %v, status %q", err, node.Status)
+	}
+}
@@ -0,0 +1,99 @@
+package executor
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// HostKeyPolicy controls how unknown and changed host keys are handled.
+type HostKeyPolicy string
+
+const (
+	// HostKeyStrict accepts only keys already present in the store.
+	HostKeyStrict HostKeyPolicy = "strict"
+	// HostKeyTOFU records the key of a host seen for the first time
+	// (trust on first use) and rejects it if it changes afterwards.
+	HostKeyTOFU HostKeyPolicy = "tofu"
+	// HostKeyInsecure disables verification. For local testing only.
+	HostKeyInsecure HostKeyPolicy = "insecure"
+)
+
+var (
+	ErrHostKeyUnknown = errors.New("host key is unknown")
+	ErrHostKeyChanged = errors.New("host key has changed")
+)
+
+// HostKeyError is returned by the host key callback when a key cannot be trusted.
+// Known is empty for hosts that have never been seen.
+type HostKeyError struct {
+	Host        string
+	Fingerprint string
+	Known       []string
+}
+
+func (e *HostKeyError) Error() string {
+	if len(e.Known) == 0 {
+		return fmt.Sprintf("host key for %s is unknown (presented %s)", e.Host, e.Fingerprint)
+	}
+	return fmt.Sprintf("host key for %s has changed, possible MITM: presented %s, known %s",
+		e.Host, e.Fingerprint, strings.Join(e.Known, ", "))
+}
+
+// Is lets callers match with errors.Is(err, ErrHostKeyUnknown/ErrHostKeyChanged).
+func (e *HostKeyError) Is(target error) bool {
+	switch target {
+	case ErrHostKeyUnknown:
+		return len(e.Known) == 0
+	case ErrHostKeyChanged:
+		return len(e.Known) > 0
+	}
+	return false
+}
+
+func newHostKeyError(host string, key ssh.PublicKey, known []ssh.PublicKey) *HostKeyError {
+	e := &HostKeyError{Host: host, Fingerprint: ssh.FingerprintSHA256(key)}
+	for _, k := range known {
+		e.Known = append(e.Known, ssh.FingerprintSHA256(k))
+	}
+	return e
+}
+
+// HostKeyStore keeps the trusted public keys of remote hosts.
+type HostKeyStore interface {
+	// Verify returns nil if key is trusted for hostname, or a *HostKeyError.
+	Verify(hostname string, remote net.Addr, key ssh.PublicKey) error
+	// Add records key as trusted for hostname.
+	Add(hostname string, key ssh.PublicKey) error
+}
+
+// NewHostKeyCallback builds an ssh.HostKeyCallback enforcing policy against store.
+func NewHostKeyCallback(policy HostKeyPolicy, store HostKeyStore) (ssh.HostKeyCallback, error) {
+	switch policy {
+	case HostKeyInsecure:
+		return ssh.InsecureIgnoreHostKey(), nil
+	case HostKeyStrict, HostKeyTOFU:
+	default:
+		return nil, fmt.Errorf("unknown host key policy %q", policy)
+	}
+	if store == nil {
+		return nil, fmt.Errorf("host key policy %q requires a host key store", policy)
+	}
+
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		err := store.Verify(hostname, remote, key)
+		if err == nil {
+			return nil
+		}
+		if policy == HostKeyTOFU && errors.Is(err, ErrHostKeyUnknown) {
+			if addErr := store.Add(hostname, key); addErr != nil {
+				return fmt.Errorf("record host key for %s: %w", hostname, addErr)
+			}
+			return nil
+		}
+		return err
+	}, nil
+}
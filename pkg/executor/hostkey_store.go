@@ -0,0 +1,138 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+var (
+	_ HostKeyStore = (*KnownHostsFile)(nil)
+	_ HostKeyStore = (*MongoHostKeyStore)(nil)
+)
+
+// KnownHostsFile is a HostKeyStore backed by an OpenSSH known_hosts file.
+// The file is re-read on every check so external edits are picked up.
+type KnownHostsFile struct {
+	Path string
+	mu   sync.Mutex
+}
+
+func NewKnownHostsFile(path string) *KnownHostsFile {
+	return &KnownHostsFile{Path: path}
+}
+
+func (f *KnownHostsFile) Verify(hostname string, remote net.Addr, key ssh.PublicKey) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, err := os.Stat(f.Path); errors.Is(err, os.ErrNotExist) {
+		return newHostKeyError(knownhosts.Normalize(hostname), key, nil)
+	}
+	cb, err := knownhosts.New(f.Path)
+	if err != nil {
+		return fmt.Errorf("load known hosts %s: %w", f.Path, err)
+	}
+
+	err = cb(hostname, remote, key)
+	var keyErr *knownhosts.KeyError
+	if errors.As(err, &keyErr) {
+		known := make([]ssh.PublicKey, 0, len(keyErr.Want))
+		for _, k := range keyErr.Want {
+			known = append(known, k.Key)
+		}
+		return newHostKeyError(knownhosts.Normalize(hostname), key, known)
+	}
+	return err
+}
+
+func (f *KnownHostsFile) Add(hostname string, key ssh.PublicKey) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(f.Path), 0700); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(f.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	line := knownhosts.Line([]string{knownhosts.Normalize(hostname)}, key)
+	_, err = file.WriteString(line + "\n")
+	return err
+}
+
+// MongoHostKeyStore is a HostKeyStore keeping one document per host:
+// {_id: <normalized host>, keys: [<authorized_keys line>...]}.
+type MongoHostKeyStore struct {
+	Collection *mongo.Collection
+	Timeout    time.Duration
+}
+
+type hostKeyDoc struct {
+	Host      string    `bson:"_id"`
+	Keys      []string  `bson:"keys"`
+	UpdatedAt time.Time `bson:"updatedAt"`
+}
+
+func NewMongoHostKeyStore(collection *mongo.Collection) *MongoHostKeyStore {
+	return &MongoHostKeyStore{Collection: collection, Timeout: 5 * time.Second}
+}
+
+func (m *MongoHostKeyStore) Verify(hostname string, _ net.Addr, key ssh.PublicKey) error {
+	ctx, cancel := context.WithTimeout(context.Background(), m.Timeout)
+	defer cancel()
+
+	host := knownhosts.Normalize(hostname)
+	var doc hostKeyDoc
+	err := m.Collection.FindOne(ctx, bson.M{"_id": host}).Decode(&doc)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return newHostKeyError(host, key, nil)
+	}
+	if err != nil {
+		return fmt.Errorf("lookup host key for %s: %w", host, err)
+	}
+
+	known := make([]ssh.PublicKey, 0, len(doc.Keys))
+	for _, line := range doc.Keys {
+		k, _, _, _, err := ssh.ParseAuthorizedKey([]byte(line))
+		if err != nil {
+			return fmt.Errorf("parse stored host key for %s: %w", host, err)
+		}
+		if k.Type() == key.Type() && bytes.Equal(k.Marshal(), key.Marshal()) {
+			return nil
+		}
+		known = append(known, k)
+	}
+	return newHostKeyError(host, key, known)
+}
+
+func (m *MongoHostKeyStore) Add(hostname string, key ssh.PublicKey) error {
+	ctx, cancel := context.WithTimeout(context.Background(), m.Timeout)
+	defer cancel()
+
+	line := string(bytes.TrimSpace(ssh.MarshalAuthorizedKey(key)))
+	_, err := m.Collection.UpdateOne(ctx,
+		bson.M{"_id": knownhosts.Normalize(hostname)},
+		bson.M{
+			"$addToSet": bson.M{"keys": line},
+			"$set":      bson.M{"updatedAt": time.Now().UTC()},
+		},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
@@ -0,0 +1,77 @@
+package executor
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func newTestHostKey(t *testing.T) ssh.PublicKey {
+	t.Helper()
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	key, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatalf("convert key: %v", err)
+	}
+	return key
+}
+
+func TestHostKeyCallbackTOFU(t *testing.T) {
+	store := NewKnownHostsFile(filepath.Join(t.TempDir(), "known_hosts"))
+	cb, err := NewHostKeyCallback(HostKeyTOFU, store)
+	if err != nil {
+		t.Fatalf("NewHostKeyCallback: %v", err)
+	}
+	addr := &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 22}
+	key := newTestHostKey(t)
+
+	if err := cb("10.0.0.1:22", addr, key); err != nil {
+		t.Fatalf("first use: %v", err)
+	}
+	if err := cb("10.0.0.1:22", addr, key); err != nil {
+		t.Fatalf("recorded key rejected: %v", err)
+	}
+
+	err = cb("10.0.0.1:22", addr, newTestHostKey(t))
+	if !errors.Is(err, ErrHostKeyChanged) {
+		t.Fatalf("changed key: got %v, want ErrHostKeyChanged", err)
+	}
+	var hkErr *HostKeyError
+	if !errors.As(err, &hkErr) || len(hkErr.Known) != 1 {
+		t.Errorf("expected HostKeyError with one known key, got %#v", err)
+	}
+}
+
+func TestHostKeyCallbackStrict(t *testing.T) {
+	store := NewKnownHostsFile(filepath.Join(t.TempDir(), "known_hosts"))
+	cb, err := NewHostKeyCallback(HostKeyStrict, store)
+	if err != nil {
+		t.Fatalf("NewHostKeyCallback: %v", err)
+	}
+	addr := &net.TCPAddr{IP: net.ParseIP("10.0.0.2"), Port: 2222}
+	key := newTestHostKey(t)
+
+	if err := cb("10.0.0.2:2222", addr, key); !errors.Is(err, ErrHostKeyUnknown) {
+		t.Fatalf("unknown host: got %v, want ErrHostKeyUnknown", err)
+	}
+	if err := store.Add("10.0.0.2:2222", key); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if err := cb("10.0.0.2:2222", addr, key); err != nil {
+		t.Errorf("known key rejected: %v", err)
+	}
+}
+
+func TestHostKeyCallbackUnknownPolicy(t *testing.T) {
+	if _, err := NewHostKeyCallback("lenient", nil); err == nil {
+		t.Error("expected error for unknown policy")
+	}
+}
@@ -2,12 +2,105 @@ package executor
 
 import (
     "context"
+    "os"
+    "time"
 )
 
 // Executor knows how to run a script over SSH (or any transport),
 // apply retries/backoff, and return the output as string slices.
 type Executor interface {
-    Run(ctx context.Context, script string) (stdoutLines, stderrLines []string, err error)
+    Run(ctx context.Context, script string, limits Limits) (*RunResult, error)
+}
+
+// Stream identifies the output stream a line was read from.
+type Stream int
+
+const (
+    Stdout Stream = iota
+    Stderr
+)
+
+// LineFunc receives output lines while a script runs. Calls are never
+// concurrent, and lines of one stream arrive in order.
+type LineFunc func(stream Stream, line string)
+
+// StreamExecutor is an Executor that delivers output while the script runs
+// instead of buffering it. Stdout and stderr are read concurrently. The
+// RunResult it returns carries no output.
+type StreamExecutor interface {
+    Executor
+    Stream(ctx context.Context, script string, limits Limits, fn LineFunc) (*RunResult, error)
+}
+
+// Prompt is a password prompt a script shows before it gets going, as sudo
+// and su do. The script prints the Ready line once it is past the prompt;
+// output before it is checked for the prompt, everything after is the
+// script's own.
+type Prompt struct {
+    Text   string // prompt to answer
+    Answer string // sent on stdin, followed by a newline
+    Ready  string // line printed once the prompt is past
+    PTY    bool   // run the script on a pseudo-terminal; stderr then arrives as stdout
+    Input  []byte // sent on stdin once the prompt is past, before stdin is closed
+}
+
+// PromptExecutor is a StreamExecutor that can answer a prompt of the script
+// on its stdin. The prompt and the Ready line are removed from the output.
+type PromptExecutor interface {
+    StreamExecutor
+    StreamPrompt(ctx context.Context, script string, limits Limits, prompt Prompt, fn LineFunc) (*RunResult, error)
+}
+
+// InputExecutor is a StreamExecutor that can send input to the script on
+// its stdin. Stdin is closed once the input is written.
+type InputExecutor interface {
+    StreamExecutor
+    StreamInput(ctx context.Context, script string, limits Limits, input []byte, fn LineFunc) (*RunResult, error)
+}
+
+// Fetcher is an Executor that can also download files from the host.
+type Fetcher interface {
+    Executor
+    Fetch(ctx context.Context, path string, opts FetchOptions) (*FetchResult, error)
+}
+
+// FetchOptions control a download. Limits.Timeout bounds the transfer and
+// Limits.MaxOutputBytes the content kept; the checksum always covers the
+// whole file.
+type FetchOptions struct {
+    Limits
+    Checksum string // sha256, sha1 or md5, see package graphproc
+    HashOnly bool   // read the file for its checksum but keep no content
+}
+
+// FetchResult is the outcome of Fetcher.Fetch.
+type FetchResult struct {
+    Content   []byte
+    Size      int64 // bytes read
+    Mode      os.FileMode
+    ModTime   time.Time
+    Checksum  string // hex digest of the whole file
+    Attempts  int
+    Truncated bool   // Content stops at MaxOutputBytes
+}
+
+// Limits bound a single run. Zero values mean no limit. Output limits apply
+// to stdout and stderr separately.
+type Limits struct {
+    Timeout        time.Duration `yaml:"timeout" json:"timeout"`
+    MaxOutputBytes int64         `yaml:"maxOutputBytes" json:"maxOutputBytes"`
+    MaxLines       int           `yaml:"maxLines" json:"maxLines"`
+}
+
+// RunResult is the outcome of Executor.Run. It is returned together with
+// the error of a script that exited with a non-zero status.
+type RunResult struct {
+    Stdout   []string
+    Stderr   []string
+    Attempts int  // tries including retries of transport failures
+    // Truncated is set when the output hit a limit; the script was killed
+    // and the output read so far is kept.
+    Truncated bool
 }
 
 // Task is responsible for taking a node + executor + processor chain,
@@ -0,0 +1,98 @@
+package executor
+
+import (
+    "context"
+    "fmt"
+    "net"
+    "strings"
+    "time"
+
+    "golang.org/x/crypto/ssh"
+)
+
+// Hop is a jump host (bastion) on the way to the target, like an entry of
+// OpenSSH ProxyJump. Each hop authenticates and verifies its host key with
+// its own client config.
+type Hop struct {
+    Addr   string
+    Config *ssh.ClientConfig
+}
+
+// hopsKey identifies a chain of jump hosts in pool keys.
+func hopsKey(hops []Hop) string {
+    parts := make([]string, len(hops))
+    for i, h := range hops {
+        parts[i] = h.Config.User + "@" + h.Addr
+    }
+    return strings.Join(parts, ",")
+}
+
+// dialChain connects to addr through the jump hosts in order and returns
+// the client of the final hop. The jump connections are closed when the
+// returned client is closed.
+func dialChain(ctx context.Context, hops []Hop, addr string, config *ssh.ClientConfig) (*ssh.Client, error) {
+    if len(hops) == 0 {
+        return dialSSH(ctx, nil, addr, config)
+    }
+    var chain []*ssh.Client
+    closeChain := func() {
+        for i := len(chain) - 1; i >= 0; i-- {
+            chain[i].Close()
+        }
+    }
+
+    var prev *ssh.Client
+    for _, hop := range hops {
+        client, err := dialSSH(ctx, prev, hop.Addr, hop.Config)
+        if err != nil {
+            closeChain()
+            return nil, fmt.Errorf("jump host %s: %w", hop.Addr, err)
+        }
+        chain = append(chain, client)
+        prev = client
+    }
+    client, err := dialSSH(ctx, prev, addr, config)
+    if err != nil {
+        closeChain()
+        return nil, err
+    }
+    go func() {
+        client.Wait()
+        closeChain()
+    }()
+    return client, nil
+}
+
+// dialSSH opens an SSH connection to addr, directly when via is nil and
+// through a direct-tcpip channel of via otherwise.
+func dialSSH(ctx context.Context, via *ssh.Client, addr string, config *ssh.ClientConfig) (*ssh.Client, error) {
+    timeout := config.Timeout
+    if timeout <= 0 {
+        timeout = 30 * time.Second
+    }
+    ctx, cancel := context.WithTimeout(ctx, timeout)
+    defer cancel()
+
+    var conn net.Conn
+    var err error
+    if via == nil {
+        var d net.Dialer
+        conn, err = d.DialContext(ctx, "tcp", addr)
+    } else {
+        conn, err = via.DialContext(ctx, "tcp", addr)
+    }
+    if err != nil {
+        return nil, err
+    }
+    // the handshake has no context of its own
+    if deadline, ok := ctx.Deadline(); ok {
+        conn.SetDeadline(deadline)
+    }
+    c, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
+    if err != nil {
+        conn.Close()
+        return nil, err
+    }
+    conn.SetDeadline(time.Time{})
+    return ssh.NewClient(c, chans, reqs), nil
+}
@@ -0,0 +1,84 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func TestNewResilientClientThroughJumpHosts(t *testing.T) {
+	first := startTestSSHServer(t)
+	second := startTestSSHServer(t)
+	target := startTestSSHServer(t)
+
+	hops := []Hop{
+		{Addr: first.Addr, Config: testClientConfig()},
+		{Addr: second.Addr, Config: testClientConfig()},
+	}
+	client, err := NewResilientClient(target.Addr, testClientConfig(), hops...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	res, err := NewSSHExecutor(client).Run(context.Background(), "echo through", Limits{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Stdout) != 1 || res.Stdout[0] != "through" {
+		t.Fatalf("stdout = %q", res.Stdout)
+	}
+	if first.forwards.Load() != 1 || second.forwards.Load() != 1 {
+		t.Fatalf("forwards = %d, %d, want 1, 1", first.forwards.Load(), second.forwards.Load())
+	}
+}
+
+func TestJumpHostKeyPolicyPerHop(t *testing.T) {
+	bastion := startTestSSHServer(t)
+	target := startTestSSHServer(t)
+
+	rejected := errors.New("unknown host key")
+	hopConfig := testClientConfig()
+	hopConfig.HostKeyCallback = func(string, net.Addr, ssh.PublicKey) error { return rejected }
+
+	_, err := NewResilientClient(target.Addr, testClientConfig(), Hop{Addr: bastion.Addr, Config: hopConfig})
+	if !errors.Is(err, rejected) {
+		t.Fatalf("err = %v, want host key rejection", err)
+	}
+	if !strings.Contains(err.Error(), bastion.Addr) {
+		t.Fatalf("err = %v, want the jump host named", err)
+	}
+	if target.conns.Load() != 0 {
+		t.Fatal("target was dialed although the jump host was rejected")
+	}
+}
+
+func TestConnPoolKeysByJumpChain(t *testing.T) {
+	bastion := startTestSSHServer(t)
+	target := startTestSSHServer(t)
+	pool := NewConnPool(PoolConfig{MaxConnsPerHost: 1})
+	defer pool.Close()
+
+	ctx := context.Background()
+	direct, err := pool.Get(ctx, target.Addr, testClientConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	direct.Close()
+	via, err := pool.GetVia(ctx, []Hop{{Addr: bastion.Addr, Config: testClientConfig()}}, target.Addr, testClientConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	via.Close()
+
+	if target.conns.Load() != 2 {
+		t.Fatalf("target connections = %d, want a separate one through the jump host", target.conns.Load())
+	}
+	if bastion.forwards.Load() != 1 {
+		t.Fatalf("forwards = %d, want 1", bastion.forwards.Load())
+	}
+}
@@ -0,0 +1,119 @@
+package executor
+
+import (
+    "bytes"
+    "context"
+    "fmt"
+    "io"
+    "os/exec"
+)
+
+// LocalExecutor runs scripts on the machine the collector runs on, with
+// the same timeout and output limits as SSHExecutor.
+type LocalExecutor struct {
+    Shell string   // defaults to /bin/sh
+    Dir   string   // working directory, the collector's when empty
+    Env   []string // extra KEY=value pairs added to the collector's environment
+}
+
+func NewLocalExecutor() *LocalExecutor {
+    return &LocalExecutor{Shell: "/bin/sh"}
+}
+
+func (e *LocalExecutor) Run(ctx context.Context, script string, limits Limits) (*RunResult, error) {
+    return collect(ctx, e, script, limits)
+}
+
+// Stream runs the script with "<shell> -c". On timeout or when a limit is
+// hit the whole process group is killed, so children of the script do not
+// keep its output open.
+func (e *LocalExecutor) Stream(ctx context.Context, script string, limits Limits, fn LineFunc) (*RunResult, error) {
+    return e.stream(ctx, script, limits, nil, nil, fn)
+}
+
+// StreamInput is Stream with input sent on the script's stdin.
+func (e *LocalExecutor) StreamInput(ctx context.Context, script string, limits Limits, input []byte, fn LineFunc) (*RunResult, error) {
+    return e.stream(ctx, script, limits, nil, input, fn)
+}
+
+// StreamPrompt is Stream for scripts that ask for a password first, see
+// Prompt. Pseudo-terminals are not supported locally.
+func (e *LocalExecutor) StreamPrompt(ctx context.Context, script string, limits Limits, prompt Prompt, fn LineFunc) (*RunResult, error) {
+    if prompt.PTY {
+        return &RunResult{}, fmt.Errorf("local executor cannot run scripts on a pseudo-terminal")
+    }
+    return e.stream(ctx, script, limits, &prompt, nil, fn)
+}
+
+func (e *LocalExecutor) stream(ctx context.Context, script string, limits Limits, prompt *Prompt, input []byte, fn LineFunc) (*RunResult, error) {
+    if limits.Timeout > 0 {
+        var cancel context.CancelFunc
+        ctx, cancel = context.WithTimeout(ctx, limits.Timeout)
+        defer cancel()
+    }
+    res := &RunResult{Attempts: 1}
+    if err := ctx.Err(); err != nil {
+        return res, err
+    }
+
+    shell := e.Shell
+    if shell == "" {
+        shell = "/bin/sh"
+    }
+    cmd := exec.Command(shell, "-c", script)
+    cmd.Dir = e.Dir
+    if len(e.Env) > 0 {
+        cmd.Env = append(cmd.Environ(), e.Env...)
+    }
+    setProcessGroup(cmd)
+
+    stdout, err := cmd.StdoutPipe()
+    if err != nil {
+        return res, fmt.Errorf("stdout pipe: %w", err)
+    }
+    stderr, err := cmd.StderrPipe()
+    if err != nil {
+        return res, fmt.Errorf("stderr pipe: %w", err)
+    }
+    var stdin io.WriteCloser
+    switch {
+    case prompt != nil:
+        if stdin, err = cmd.StdinPipe(); err != nil {
+            return res, fmt.Errorf("stdin pipe: %w", err)
+        }
+    case input != nil:
+        cmd.Stdin = bytes.NewReader(input)
+    }
+    if err := cmd.Start(); err != nil {
+        return res, fmt.Errorf("start script: %w", err)
+    }
+
+    kill := func() { killProcessGroup(cmd) }
+    var out, errOut io.Reader = stdout, stderr
+    var p *prompter
+    if prompt != nil {
+        p = newPrompter(*prompt, stdin, kill)
+        out, errOut = p.reader(stdout), p.reader(stderr)
+    }
+    done := make(chan struct{})
+    defer close(done)
+    go func() {
+        select {
+        case <-ctx.Done():
+            kill()
+        case <-done:
+        }
+    }()
+
+    res.Truncated = streamOutput(out, errOut, limits, kill, fn)
+    err = cmd.Wait()
+    switch {
+    case p != nil && p.Err() != nil:
+        return res, p.Err()
+    case ctx.Err() != nil:
+        return res, fmt.Errorf("script killed: %w", ctx.Err())
+    case res.Truncated:
+        return res, nil
+    }
+    return res, err
+}
@@ -0,0 +1,73 @@
+package executor
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	gp "github.com/andrej220/HAM/pkg/graphproc"
+)
+
+func TestLocalExecutorRun(t *testing.T) {
+	e := NewLocalExecutor()
+	e.Env = []string{"HAM_TEST=value"}
+	res, err := e.Run(context.Background(), "echo $HAM_TEST; echo warn >&2", Limits{})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if !reflect.DeepEqual(res.Stdout, []string{"value"}) || !reflect.DeepEqual(res.Stderr, []string{"warn"}) {
+		t.Errorf("stdout %v, stderr %v", res.Stdout, res.Stderr)
+	}
+
+	_, err = e.Run(context.Background(), "exit 4", Limits{})
+	if code, ok := ExitCode(err); !ok || code != 4 {
+		t.Errorf("exit code %d, %v (err %v)", code, ok, err)
+	}
+}
+
+func TestLocalExecutorLimits(t *testing.T) {
+	e := NewLocalExecutor()
+
+	node := &gp.Node{ID: "n", Script: "echo started; sleep 10 & wait", Timeout: gp.Duration(200 * time.Millisecond)}
+	start := time.Now()
+	if err := NewNodeTask(node, e).Execute(context.Background()); err == nil {
+		t.Fatal("expected timeout error")
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("script and its children were not killed, took %s", d)
+	}
+	if node.Status != gp.StatusTimeout || node.Result != "started" {
+		t.Errorf("status %q, result %v", node.Status, node.Result)
+	}
+
+	node = &gp.Node{ID: "n", Type: "array", Script: "yes", MaxLines: 3}
+	if err := NewNodeTask(node, e).Execute(context.Background()); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if !node.Truncated || !reflect.DeepEqual(node.Result, []any{"y", "y", "y"}) {
+		t.Errorf("truncated %v, result %v", node.Truncated, node.Result)
+	}
+}
+
+func TestLocalExecutorLongLine(t *testing.T) {
+	e := NewLocalExecutor()
+	script := "head -c 100000 /dev/zero | tr '\\0' x; echo; echo after"
+
+	res, err := e.Run(context.Background(), script, Limits{MaxOutputBytes: 200000})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if res.Truncated || len(res.Stdout) != 2 || len(res.Stdout[0]) != 100000 || res.Stdout[1] != "after" {
+		t.Errorf("truncated %v, %d lines", res.Truncated, len(res.Stdout))
+	}
+
+	// beyond the scanner's default buffer the output ends like at a limit
+	res, err = e.Run(context.Background(), script+"; sleep 10", Limits{})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if !res.Truncated || len(res.Stdout) != 0 {
+		t.Errorf("truncated %v, stdout %d lines", res.Truncated, len(res.Stdout))
+	}
+}
@@ -0,0 +1,23 @@
+//go:build unix
+
+package executor
+
+import (
+    "os/exec"
+    "syscall"
+)
+
+// setProcessGroup starts the script in its own process group.
+func setProcessGroup(cmd *exec.Cmd) {
+    cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// killProcessGroup kills the script and everything it started.
+func killProcessGroup(cmd *exec.Cmd) {
+    if cmd.Process == nil {
+        return
+    }
+    if err := syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL); err != nil {
+        cmd.Process.Kill()
+    }
+}
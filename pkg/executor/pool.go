@@ -0,0 +1,309 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+	"golang.org/x/crypto/ssh"
+)
+
+var ErrPoolClosed = errors.New("connection pool is closed")
+
+// maxDialRetries bounds the redials of an unreachable host.
+const maxDialRetries = 3
+
+// PoolConfig controls how SSH connections are shared between jobs.
+type PoolConfig struct {
+	// IdleTimeout closes connections without leases for this long.
+	IdleTimeout time.Duration `yaml:"idleTimeout" json:"idleTimeout"`
+	// MaxSessionsPerConn caps concurrent sessions on one connection
+	// (OpenSSH servers default to MaxSessions 10).
+	MaxSessionsPerConn int `yaml:"maxSessionsPerConn" json:"maxSessionsPerConn"`
+	// MaxConnsPerHost caps connections opened to one address and user.
+	MaxConnsPerHost int `yaml:"maxConnsPerHost" json:"maxConnsPerHost"`
+	// KeepAliveInterval is the period of the keepalive health check.
+	KeepAliveInterval time.Duration `yaml:"keepAliveInterval" json:"keepAliveInterval"`
+	// KeepAliveTimeout marks a connection broken if the server does not reply in time.
+	KeepAliveTimeout time.Duration `yaml:"keepAliveTimeout" json:"keepAliveTimeout"`
+}
+
+func DefaultPoolConfig() PoolConfig {
+	return PoolConfig{
+		IdleTimeout:        5 * time.Minute,
+		MaxSessionsPerConn: 10,
+		MaxConnsPerHost:    2,
+		KeepAliveInterval:  30 * time.Second,
+		KeepAliveTimeout:   10 * time.Second,
+	}
+}
+
+// withDefaults fills zero fields from DefaultPoolConfig.
+func (c PoolConfig) withDefaults() PoolConfig {
+	def := DefaultPoolConfig()
+	if c.IdleTimeout <= 0 {
+		c.IdleTimeout = def.IdleTimeout
+	}
+	if c.MaxSessionsPerConn <= 0 {
+		c.MaxSessionsPerConn = def.MaxSessionsPerConn
+	}
+	if c.MaxConnsPerHost <= 0 {
+		c.MaxConnsPerHost = def.MaxConnsPerHost
+	}
+	if c.KeepAliveInterval <= 0 {
+		c.KeepAliveInterval = def.KeepAliveInterval
+	}
+	if c.KeepAliveTimeout <= 0 {
+		c.KeepAliveTimeout = def.KeepAliveTimeout
+	}
+	return c
+}
+
+// ConnPool keeps SSH connections per address and user and hands out leases
+// on them. All leases for a host share one circuit breaker, so failures
+// accumulate across jobs.
+type ConnPool struct {
+	cfg   PoolConfig
+	mu    sync.Mutex
+	hosts map[string]*hostConns
+	quit  chan struct{}
+	done  chan struct{}
+}
+
+type hostConns struct {
+	resConf *ResilienceConfig
+	conns   []*pooledConn
+}
+
+type pooledConn struct {
+	client   *ssh.Client
+	sessions chan struct{}
+	leases   int
+	lastUsed time.Time
+	broken   bool
+}
+
+func NewConnPool(cfg PoolConfig) *ConnPool {
+	p := &ConnPool{
+		cfg:   cfg.withDefaults(),
+		hosts: make(map[string]*hostConns),
+		quit:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	go p.maintain()
+	return p
+}
+
+func poolKey(addr, user string) string {
+	return user + "@" + addr
+}
+
+// Get returns a client for addr leased from the pool, dialing a new
+// connection if none has spare capacity. Close on the returned client
+// gives the lease back.
+func (p *ConnPool) Get(ctx context.Context, addr string, config *ssh.ClientConfig) (*ResilientSSHClient, error) {
+	return p.GetVia(ctx, nil, addr, config)
+}
+
+// GetVia is Get for targets behind jump hosts. Connections are pooled per
+// chain of hops, and the circuit breaker counts failures of the whole
+// chain against the target.
+func (p *ConnPool) GetVia(ctx context.Context, hops []Hop, addr string, config *ssh.ClientConfig) (*ResilientSSHClient, error) {
+	key := poolKey(addr, config.User)
+	if len(hops) > 0 {
+		key += " via " + hopsKey(hops)
+	}
+
+	p.mu.Lock()
+	if p.hosts == nil {
+		p.mu.Unlock()
+		return nil, ErrPoolClosed
+	}
+	host, ok := p.hosts[key]
+	if !ok {
+		host = &hostConns{resConf: DefaultResilienceConfig("ssh-" + key)}
+		p.hosts[key] = host
+	}
+	pc := host.pick(p.cfg)
+	if pc != nil {
+		client := p.lease(host, pc)
+		p.mu.Unlock()
+		return client, nil
+	}
+	p.mu.Unlock()
+
+	client, err := p.dial(ctx, host.resConf, hops, addr, config)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.hosts == nil {
+		client.Close()
+		return nil, ErrPoolClosed
+	}
+	pc = &pooledConn{
+		client:   client,
+		sessions: make(chan struct{}, p.cfg.MaxSessionsPerConn),
+	}
+	host.conns = append(host.conns, pc)
+	return p.lease(host, pc), nil
+}
+
+// pick returns the least leased healthy connection with spare capacity.
+// It returns nil when a new connection should be dialed; once the host is
+// at MaxConnsPerHost the least leased connection is shared regardless.
+func (h *hostConns) pick(cfg PoolConfig) *pooledConn {
+	var best *pooledConn
+	for _, pc := range h.conns {
+		if pc.broken {
+			continue
+		}
+		if best == nil || pc.leases < best.leases {
+			best = pc
+		}
+	}
+	if best != nil && best.leases < cfg.MaxSessionsPerConn {
+		return best
+	}
+	if best != nil && len(h.conns) >= cfg.MaxConnsPerHost {
+		return best
+	}
+	return nil
+}
+
+// lease must be called with p.mu held.
+func (p *ConnPool) lease(host *hostConns, pc *pooledConn) *ResilientSSHClient {
+	pc.leases++
+	pc.lastUsed = time.Now()
+	var once sync.Once
+	return &ResilientSSHClient{
+		SSHClient: pc.client,
+		ResConf:   host.resConf,
+		sessions:  pc.sessions,
+		release: func() {
+			once.Do(func() {
+				p.mu.Lock()
+				defer p.mu.Unlock()
+				pc.leases--
+				pc.lastUsed = time.Now()
+				if pc.broken && pc.leases == 0 {
+					pc.client.Close()
+				}
+			})
+		},
+	}
+}
+
+// dial connects through the host circuit breaker, retrying with backoff.
+func (p *ConnPool) dial(ctx context.Context, resConf *ResilienceConfig, hops []Hop, addr string, config *ssh.ClientConfig) (*ssh.Client, error) {
+	var client *ssh.Client
+	operation := func() error {
+		res, err := resConf.CircuitBreaker.Execute(func() (any, error) {
+			return dialChain(ctx, hops, addr, config)
+		})
+		if err != nil {
+			// a rejected host key will not change on retry
+			if errors.Is(err, ErrHostKeyUnknown) || errors.Is(err, ErrHostKeyChanged) {
+				return backoff.Permanent(err)
+			}
+			return err
+		}
+		client = res.(*ssh.Client)
+		return nil
+	}
+	b := backoff.WithMaxRetries(resConf.NewBackOff(), maxDialRetries)
+	if err := backoff.Retry(operation, backoff.WithContext(b, ctx)); err != nil {
+		return nil, fmt.Errorf("failed to dial  %w", err)
+	}
+	return client, nil
+}
+
+// maintain runs keepalive health checks and closes idle connections.
+func (p *ConnPool) maintain() {
+	defer close(p.done)
+	ticker := time.NewTicker(p.cfg.KeepAliveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			p.checkConns()
+		case <-p.quit:
+			return
+		}
+	}
+}
+
+func (p *ConnPool) checkConns() {
+	p.mu.Lock()
+	var check []*pooledConn
+	for _, host := range p.hosts {
+		alive := host.conns[:0]
+		for _, pc := range host.conns {
+			idle := pc.leases == 0 && time.Since(pc.lastUsed) > p.cfg.IdleTimeout
+			if idle || (pc.broken && pc.leases == 0) {
+				pc.client.Close()
+				continue
+			}
+			alive = append(alive, pc)
+			if !pc.broken {
+				check = append(check, pc)
+			}
+		}
+		host.conns = alive
+	}
+	p.mu.Unlock()
+
+	for _, pc := range check {
+		if err := keepAlive(pc.client, p.cfg.KeepAliveTimeout); err != nil {
+			log.Printf("SSH keepalive to %s failed: %v", pc.client.RemoteAddr(), err)
+			p.mu.Lock()
+			pc.broken = true
+			if pc.leases == 0 {
+				pc.client.Close()
+			}
+			p.mu.Unlock()
+		}
+	}
+}
+
+// keepAlive sends an OpenSSH keepalive request and waits for the reply.
+func keepAlive(client *ssh.Client, timeout time.Duration) error {
+	errCh := make(chan error, 1)
+	go func() {
+		_, _, err := client.SendRequest("keepalive@openssh.com", true, nil)
+		errCh <- err
+	}()
+	select {
+	case err := <-errCh:
+		return err
+	case <-time.After(timeout):
+		return fmt.Errorf("keepalive timed out after %s", timeout)
+	}
+}
+
+// Close stops health checks and closes every pooled connection.
+func (p *ConnPool) Close() error {
+	p.mu.Lock()
+	if p.hosts == nil {
+		p.mu.Unlock()
+		return nil
+	}
+	hosts := p.hosts
+	p.hosts = nil
+	p.mu.Unlock()
+
+	close(p.quit)
+	<-p.done
+	for _, host := range hosts {
+		for _, pc := range host.conns {
+			pc.client.Close()
+		}
+	}
+	return nil
+}
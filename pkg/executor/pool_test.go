@@ -0,0 +1,92 @@
+package executor
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestConnPoolReusesConnections(t *testing.T) {
+	srv := startTestSSHServer(t)
+	pool := NewConnPool(PoolConfig{MaxConnsPerHost: 1})
+	defer pool.Close()
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		client, err := pool.Get(ctx, srv.Addr, testClientConfig())
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		res, err := NewSSHExecutor(client).Run(ctx, "echo hello", Limits{})
+		if err != nil {
+			t.Fatalf("Run: %v", err)
+		}
+		if !reflect.DeepEqual(res.Stdout, []string{"hello"}) {
+			t.Errorf("got %v", res.Stdout)
+		}
+		client.Close()
+	}
+
+	if n := srv.conns.Load(); n != 1 {
+		t.Errorf("expected 1 connection to the server, got %d", n)
+	}
+}
+
+func TestConnPoolSharesBreakerPerHost(t *testing.T) {
+	srv := startTestSSHServer(t)
+	pool := NewConnPool(PoolConfig{})
+	defer pool.Close()
+	ctx := context.Background()
+
+	a, err := pool.Get(ctx, srv.Addr, testClientConfig())
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	defer a.Close()
+	b, err := pool.Get(ctx, srv.Addr, testClientConfig())
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	defer b.Close()
+
+	if a.ResConf.CircuitBreaker != b.ResConf.CircuitBreaker {
+		t.Error("leases for the same host use different circuit breakers")
+	}
+}
+
+func TestConnPoolClosed(t *testing.T) {
+	pool := NewConnPool(PoolConfig{})
+	pool.Close()
+	if _, err := pool.Get(context.Background(), "127.0.0.1:1", testClientConfig()); err != ErrPoolClosed {
+		t.Errorf("got %v, want ErrPoolClosed", err)
+	}
+}
+
+func TestConnPoolDialGivesUp(t *testing.T) {
+	// a port nothing listens on
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	pool := NewConnPool(PoolConfig{})
+	defer pool.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := pool.GetVia(context.Background(), nil, addr, testClientConfig())
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("GetVia to a dead address succeeded")
+		}
+	case <-time.After(30 * time.Second):
+		t.Fatal("GetVia to a dead address did not give up")
+	}
+}
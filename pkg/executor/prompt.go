@@ -0,0 +1,175 @@
+package executor
+
+import (
+    "bytes"
+    "errors"
+    "io"
+    "sync"
+)
+
+// ErrPromptRejected is returned when a script prompts again after the
+// answer was sent, usually because the password was wrong. The script is
+// killed rather than answered twice.
+var ErrPromptRejected = errors.New("prompt repeated, answer rejected")
+
+// prompter answers the prompt of one script run. It is shared by the
+// readers of stdout and stderr since either may carry the prompt.
+type prompter struct {
+    prompt Prompt
+    stdin  io.WriteCloser
+    kill   func()
+
+    mu       sync.Mutex
+    answered bool
+    ready    bool
+    err      error
+}
+
+func newPrompter(prompt Prompt, stdin io.WriteCloser, kill func()) *prompter {
+    return &prompter{prompt: prompt, stdin: stdin, kill: kill}
+}
+
+// answer sends the answer on the first prompt and kills the script on
+// the next one.
+func (p *prompter) answer() {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    if p.answered {
+        p.err = ErrPromptRejected
+        p.kill()
+        return
+    }
+    p.answered = true
+    if _, err := io.WriteString(p.stdin, p.prompt.Answer+"\n"); err != nil {
+        p.err = err
+        p.kill()
+    }
+}
+
+// setReady sends Prompt.Input and closes stdin once the prompt is past, so
+// scripts reading their input see EOF as they would without a prompt. The
+// input is written in the background since the script may produce output
+// while reading it.
+func (p *prompter) setReady() {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    if p.ready {
+        return
+    }
+    p.ready = true
+    if len(p.prompt.Input) == 0 {
+        p.stdin.Close()
+        return
+    }
+    go func() {
+        p.stdin.Write(p.prompt.Input)
+        p.stdin.Close()
+    }()
+}
+
+func (p *prompter) isReady() bool {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    return p.ready
+}
+
+// Err returns why the prompt could not be answered, if it could not.
+func (p *prompter) Err() error {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    return p.err
+}
+
+// reader wraps an output stream of the script, answering the prompt and
+// dropping it and the ready line from the output.
+func (p *prompter) reader(r io.Reader) io.Reader {
+    return &promptReader{p: p, r: r}
+}
+
+type promptReader struct {
+    p       *prompter
+    r       io.Reader
+    pending []byte // read but not checked yet
+    out     []byte // checked, to be returned
+    err     error
+    // answered is set after a prompt was answered on this stream, whose
+    // newline is then dropped as well
+    answered bool
+}
+
+func (pr *promptReader) Read(b []byte) (int, error) {
+    for len(pr.out) == 0 {
+        if pr.err != nil {
+            return 0, pr.err
+        }
+        buf := make([]byte, 4096)
+        n, err := pr.r.Read(buf)
+        pr.pending = append(pr.pending, buf[:n]...)
+        pr.err = err
+        pr.scan()
+    }
+    n := copy(b, pr.out)
+    pr.out = pr.out[n:]
+    return n, nil
+}
+
+// scan moves checked bytes from pending to out. Until the ready line shows
+// up it answers prompts and holds back a tail that may be the start of the
+// prompt or the ready line.
+func (pr *promptReader) scan() {
+    text, ready := []byte(pr.p.prompt.Text), []byte(pr.p.prompt.Ready)
+    for !pr.p.isReady() {
+        if pr.answered {
+            if (len(pr.pending) == 0 || string(pr.pending) == "\r") && pr.err == nil {
+                return
+            }
+            pr.pending = bytes.TrimPrefix(bytes.TrimPrefix(pr.pending, []byte("\r")), []byte("\n"))
+            pr.answered = false
+        }
+        i := bytes.Index(pr.pending, text)
+        j := bytes.Index(pr.pending, ready)
+        if i >= 0 && (j < 0 || i < j) {
+            pr.take(i, i+len(text))
+            pr.p.answer()
+            pr.answered = true
+            continue
+        }
+        if j >= 0 {
+            end := bytes.IndexByte(pr.pending[j:], '\n')
+            if end < 0 && pr.err == nil {
+                return
+            }
+            if end < 0 {
+                end = len(pr.pending) - j - 1
+            }
+            pr.take(j, j+end+1)
+            pr.p.setReady()
+            break
+        }
+        keep := max(overlap(pr.pending, text), overlap(pr.pending, ready))
+        if pr.err != nil {
+            keep = 0
+        }
+        pr.take(len(pr.pending)-keep, len(pr.pending)-keep)
+        return
+    }
+    pr.out = append(pr.out, pr.pending...)
+    pr.pending = nil
+}
+
+// take passes pending[:from] on and drops pending[from:to].
+func (pr *promptReader) take(from, to int) {
+    pr.out = append(pr.out, pr.pending[:from]...)
+    pr.pending = pr.pending[to:]
+}
+
+// overlap returns the length of the longest proper prefix of s that b
+// ends with.
+func overlap(b, s []byte) int {
+    for k := min(len(s)-1, len(b)); k > 0; k-- {
+        if bytes.HasSuffix(b, s[:k]) {
+            return k
+        }
+    }
+    return 0
+}
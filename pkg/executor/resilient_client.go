@@ -1,6 +1,7 @@
 package executor
 
 import(
+		"context"
 		"github.com/sony/gobreaker"
 		"golang.org/x/crypto/ssh"
 		"time"
@@ -23,6 +24,8 @@ type ResilienceConfig struct {
 type ResilientSSHClient struct {
     SSHClient  	*ssh.Client
 	ResConf 	*ResilienceConfig
+	sessions	chan struct{}	// limits concurrent sessions, nil means unlimited
+	release		func()			// set for pooled clients, returns the lease
 }
 
 func NewResilienceConfig(defaultBackOff *backoff.ExponentialBackOff, cbs gobreaker.Settings, cb *gobreaker.CircuitBreaker)(*ResilienceConfig){
@@ -33,22 +36,11 @@ func NewResilienceConfig(defaultBackOff *backoff.ExponentialBackOff, cbs gobreak
 	}
 }
 
-func (r *ResilienceConfig) Configure(backoffSettings *backoff.ExponentialBackOff, cbSettings gobreaker.Settings) {
-    r.BackoffSettings = backoffSettings
-    r.CircuitBreakerSettings = cbSettings
-}
-
-func (c *ResilientSSHClient) Close() error {
-    return c.SSHClient.Close()
-}
-
-func NewResilientClient(remote string,  config *ssh.ClientConfig) (*ResilientSSHClient, error) {
-	client, err := ssh.Dial("tcp", remote, config)
-	if err != nil {
-		return nil, fmt.Errorf("failed to dial  %w", err)
-	}
+// DefaultResilienceConfig returns the backoff and circuit breaker settings
+// used for a single remote host.
+func DefaultResilienceConfig(name string) *ResilienceConfig {
 	cbs := gobreaker.Settings{
-		Name:        "ssh-connection",
+		Name:        name,
 		MaxRequests: 5,         
 		Interval:    1 * time.Minute,
 		Timeout:     30 * time.Second,
@@ -56,22 +48,75 @@ func NewResilientClient(remote string,  config *ssh.ClientConfig) (*ResilientSSH
 			return counts.ConsecutiveFailures > 5
 		},
 	}
-	resConfig := NewResilienceConfig(
+	return NewResilienceConfig(
 		&backoff.ExponentialBackOff{
 			InitialInterval:     500 * time.Millisecond,
 			MaxInterval:         5 * time.Second,
 			Multiplier:          1.5,
 			RandomizationFactor: 0.5,
+			MaxElapsedTime:      30 * time.Second,
 			Stop:                backoff.Stop,
 			Clock:               backoff.SystemClock,
 		},
 		cbs,
 		gobreaker.NewCircuitBreaker(cbs),
 	)
+}
+
+func (r *ResilienceConfig) Configure(backoffSettings *backoff.ExponentialBackOff, cbSettings gobreaker.Settings) {
+    r.BackoffSettings = backoffSettings
+    r.CircuitBreakerSettings = cbSettings
+}
+
+// NewBackOff returns a fresh copy of the backoff settings. ExponentialBackOff
+// is stateful, so every retry loop needs its own instance.
+func (r *ResilienceConfig) NewBackOff() backoff.BackOff {
+	b := *r.BackoffSettings
+	b.Reset()
+	return &b
+}
 
+// NewSession opens a session through the circuit breaker. It waits for a free
+// slot when the client limits concurrent sessions. The returned release func
+// must be called after the session is closed.
+func (c *ResilientSSHClient) NewSession(ctx context.Context) (*ssh.Session, func(), error) {
+	release := func() {}
+	if c.sessions != nil {
+		select {
+		case c.sessions <- struct{}{}:
+			release = func() { <-c.sessions }
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		}
+	}
+	res, err := c.ResConf.CircuitBreaker.Execute(func() (any, error) {
+		return c.SSHClient.NewSession()
+	})
+	if err != nil {
+		release()
+		return nil, nil, err
+	}
+	return res.(*ssh.Session), release, nil
+}
+
+// Close closes the connection, or returns it to the pool for pooled clients.
+func (c *ResilientSSHClient) Close() error {
+	if c.release != nil {
+		c.release()
+		return nil
+	}
+    return c.SSHClient.Close()
+}
+
+// NewResilientClient connects to remote, through the jump hosts in order
+// if any are given. The circuit breaker guards the final hop.
+func NewResilientClient(remote string,  config *ssh.ClientConfig, jumps ...Hop) (*ResilientSSHClient, error) {
+	client, err := dialChain(context.Background(), jumps, remote, config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial  %w", err)
+	}
     return &ResilientSSHClient{
         SSHClient: client,
- 		ResConf: resConfig,
+ 		ResConf: DefaultResilienceConfig("ssh-connection"),
     }, nil
 }
-
@@ -2,10 +2,14 @@ package executor
 
 import (
     "bufio"
+    "bytes"
     "context"
+    "errors"
     "fmt"
     "io"
     "log"
+    "os/exec"
+    "sync"
 
     "github.com/cenkalti/backoff/v4"
     "golang.org/x/crypto/ssh"
@@ -21,18 +25,45 @@ func NewSSHExecutor(client *ResilientSSHClient) *SSHExecutor {
     return &SSHExecutor{client: client}
 }
 
-func (e *SSHExecutor) Run(ctx context.Context, script string) ([]string, []string, error) {
-    var outLines, errLines []string
+func (e *SSHExecutor) Run(ctx context.Context, script string, limits Limits) (*RunResult, error) {
+    return collect(ctx, e, script, limits)
+}
+
+// Stream runs the script and passes every output line to fn as it is read.
+// Only failures to start the script are retried; once it runs, a broken
+// connection is returned as an error since the script may have had effects
+// and fn has already seen part of its output.
+func (e *SSHExecutor) Stream(ctx context.Context, script string, limits Limits, fn LineFunc) (*RunResult, error) {
+    return e.stream(ctx, script, limits, nil, nil, fn)
+}
+
+// StreamInput is Stream with input sent on the script's stdin.
+func (e *SSHExecutor) StreamInput(ctx context.Context, script string, limits Limits, input []byte, fn LineFunc) (*RunResult, error) {
+    return e.stream(ctx, script, limits, nil, input, fn)
+}
+
+// StreamPrompt is Stream for scripts that ask for a password first, see
+// Prompt.
+func (e *SSHExecutor) StreamPrompt(ctx context.Context, script string, limits Limits, prompt Prompt, fn LineFunc) (*RunResult, error) {
+    return e.stream(ctx, script, limits, &prompt, nil, fn)
+}
+
+func (e *SSHExecutor) stream(ctx context.Context, script string, limits Limits, prompt *Prompt, input []byte, fn LineFunc) (*RunResult, error) {
+    if limits.Timeout > 0 {
+        var cancel context.CancelFunc
+        ctx, cancel = context.WithTimeout(ctx, limits.Timeout)
+        defer cancel()
+    }
+    res := &RunResult{}
 
     operation := func() error {
+        res.Attempts++
         // open session via circuit-breaker
-        res, err := e.client.ResConf.CircuitBreaker.Execute(func() (any, error) {
-            return e.client.SSHClient.NewSession()
-        })
+        sess, release, err := e.client.NewSession(ctx)
         if err != nil {
             return fmt.Errorf("new session: %w", err)
         }
-        sess := res.(*ssh.Session)
+        defer release()
         defer sess.Close()
 
         // pipes
@@ -44,37 +75,172 @@ func (e *SSHExecutor) Run(ctx context.Context, script string) ([]string, []strin
         if err != nil {
             return fmt.Errorf("stderr pipe: %w", err)
         }
+        var stdin io.WriteCloser
+        switch {
+        case prompt != nil:
+            if stdin, err = sess.StdinPipe(); err != nil {
+                return fmt.Errorf("stdin pipe: %w", err)
+            }
+            if prompt.PTY {
+                modes := ssh.TerminalModes{ssh.ECHO: 0, ssh.ONLCR: 0}
+                if err := sess.RequestPty("xterm", 24, 200, modes); err != nil {
+                    return fmt.Errorf("request pty: %w", err)
+                }
+            }
+        case input != nil:
+            sess.Stdin = bytes.NewReader(input)
+        }
 
-        // start + scan
         if err := sess.Start(script); err != nil {
             return fmt.Errorf("start script: %w", err)
         }
-        outLines = scanLines(ctx, stdout)
-        errLines = scanLines(ctx, stderr)
+        kill := killer(sess)
+        var p *prompter
+        if prompt != nil {
+            p = newPrompter(*prompt, stdin, kill)
+            stdout, stderr = p.reader(stdout), p.reader(stderr)
+        }
+        done := make(chan struct{})
+        defer close(done)
+        go func() {
+            select {
+            case <-ctx.Done():
+                kill()
+            case <-done:
+            }
+        }()
 
-        return sess.Wait()
+        res.Truncated = streamOutput(stdout, stderr, limits, kill, fn)
+
+        err = sess.Wait()
+        switch {
+        case p != nil && p.Err() != nil:
+            return backoff.Permanent(p.Err())
+        case ctx.Err() != nil:
+            return backoff.Permanent(fmt.Errorf("script killed: %w", ctx.Err()))
+        case res.Truncated:
+            return nil
+        case err != nil:
+            // a non-zero exit status is the script's result, anything else
+            // a transport failure after the script started
+            return backoff.Permanent(err)
+        }
+        return nil
     }
 
-    b := backoff.WithContext(e.client.ResConf.BackoffSettings, ctx)
+    b := backoff.WithContext(e.client.ResConf.NewBackOff(), ctx)
     if err := backoff.Retry(operation, b); err != nil {
-        return nil, nil, err
+        return res, err
+    }
+    return res, nil
+}
+
+// collect runs a StreamExecutor and buffers its output. The result keeps
+// what the script wrote even if it failed or was killed.
+func collect(ctx context.Context, e StreamExecutor, script string, limits Limits) (*RunResult, error) {
+    var stdout, stderr []string
+    res, err := e.Stream(ctx, script, limits, func(stream Stream, line string) {
+        if stream == Stderr {
+            stderr = append(stderr, line)
+            return
+        }
+        stdout = append(stdout, line)
+    })
+    if res == nil {
+        res = &RunResult{}
+    }
+    res.Stdout, res.Stderr = stdout, stderr
+    return res, err
+}
+
+// streamOutput reads stdout and stderr concurrently, passing lines to fn
+// one at a time. It reports whether a limit was hit.
+func streamOutput(stdout, stderr io.Reader, limits Limits, onLimit func(), fn LineFunc) bool {
+    var (
+        mu        sync.Mutex
+        wg        sync.WaitGroup
+        truncated [2]bool
+    )
+    for i, r := range []io.Reader{stdout, stderr} {
+        stream := Stream(i)
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            truncated[stream] = scanLines(r, limits, onLimit, func(line string) {
+                mu.Lock()
+                defer mu.Unlock()
+                fn(stream, line)
+            })
+        }()
+    }
+    wg.Wait()
+    return truncated[Stdout] || truncated[Stderr]
+}
+
+// killer returns a function that kills the remote command and closes the
+// session so pending reads return. It is safe to call more than once.
+func killer(sess *ssh.Session) func() {
+    var once sync.Once
+    return func() {
+        once.Do(func() {
+            sess.Signal(ssh.SIGKILL)
+            sess.Close()
+        })
+    }
+}
+
+// ExitCode extracts the exit status of a command from an error
+// returned by Executor.Run. ok is false if the command did not exit normally.
+func ExitCode(err error) (code int, ok bool) {
+    if err == nil {
+        return 0, true
     }
-    return outLines, errLines, nil
+    var exitErr *ssh.ExitError
+    if errors.As(err, &exitErr) {
+        return exitErr.ExitStatus(), true
+    }
+    var localErr *exec.ExitError
+    if errors.As(err, &localErr) && localErr.ExitCode() >= 0 {
+        return localErr.ExitCode(), true
+    }
+    return 0, false
 }
 
-func scanLines(ctx context.Context, r io.Reader) []string {
+// scanLines passes lines to emit until EOF or until a limit of limits is
+// reached, in which case it calls onLimit, drains r and reports truncated.
+// Lines fit up to MaxOutputBytes, at least 64 KiB; a longer line, or any
+// other read error, ends the output like a limit does.
+func scanLines(r io.Reader, limits Limits, onLimit func(), emit func(string)) (truncated bool) {
     scanner := bufio.NewScanner(r)
-    var lines []string
+    scanner.Buffer(nil, int(max(bufio.MaxScanTokenSize, limits.MaxOutputBytes)))
+    var size int64
+    var lines int
     for scanner.Scan() {
-        select {
-        case <-ctx.Done():
-            return lines
-        default:
-            lines = append(lines, scanner.Text())
+        line := scanner.Text()
+        if limits.MaxLines > 0 && lines >= limits.MaxLines {
+            truncated = true
+            break
         }
+        if limits.MaxOutputBytes > 0 && size+int64(len(line))+1 > limits.MaxOutputBytes {
+            // keep the part of the line that still fits
+            if rest := limits.MaxOutputBytes - size; rest > 0 {
+                emit(line[:rest])
+            }
+            truncated = true
+            break
+        }
+        size += int64(len(line)) + 1
+        lines++
+        emit(line)
     }
     if err := scanner.Err(); err != nil {
         log.Printf("scan error: %v", err)
+        truncated = true
+    }
+    if truncated {
+        onLimit()
+        io.Copy(io.Discard, r)
+        return true
     }
-    return lines
+    return false
 }
@@ -0,0 +1,225 @@
+package executor
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"os/exec"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// testSSHServer is a minimal SSH server for tests. "exec" requests are run
+// with /bin/sh -c on the local machine, the "sftp" subsystem serves local
+// files, and direct-tcpip channels are
+// forwarded so the server can act as a jump host.
+type testSSHServer struct {
+	Addr  string
+	conns atomic.Int32
+	// forwards counts direct-tcpip channels opened through the server.
+	forwards atomic.Int32
+	ln    net.Listener
+	wg    sync.WaitGroup
+}
+
+func startTestSSHServer(t *testing.T) *testSSHServer {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &ssh.ServerConfig{
+		PasswordCallback: func(c ssh.ConnMetadata, pass []byte) (*ssh.Permissions, error) {
+			if string(pass) == "test" {
+				return nil, nil
+			}
+			return nil, errors.New("denied")
+		},
+	}
+	cfg.AddHostKey(signer)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &testSSHServer{Addr: ln.Addr().String(), ln: ln}
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		for {
+			nc, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			s.conns.Add(1)
+			s.wg.Add(1)
+			go func() {
+				defer s.wg.Done()
+				s.serveConn(nc, cfg)
+			}()
+		}
+	}()
+	t.Cleanup(func() {
+		ln.Close()
+	})
+	return s
+}
+
+func (s *testSSHServer) serveConn(nc net.Conn, cfg *ssh.ServerConfig) {
+	conn, chans, reqs, err := ssh.NewServerConn(nc, cfg)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	go func() {
+		for req := range reqs {
+			if req.WantReply {
+				req.Reply(req.Type == "keepalive@openssh.com", nil)
+			}
+		}
+	}()
+	for nch := range chans {
+		if nch.ChannelType() == "direct-tcpip" {
+			s.forwards.Add(1)
+			go forward(nch)
+			continue
+		}
+		if nch.ChannelType() != "session" {
+			nch.Reject(ssh.UnknownChannelType, "unsupported")
+			continue
+		}
+		ch, chReqs, err := nch.Accept()
+		if err != nil {
+			continue
+		}
+		go serveSession(ch, chReqs)
+	}
+}
+
+// forward connects a direct-tcpip channel to the requested address.
+func forward(nch ssh.NewChannel) {
+	var target struct {
+		Host       string
+		Port       uint32
+		OriginHost string
+		OriginPort uint32
+	}
+	if err := ssh.Unmarshal(nch.ExtraData(), &target); err != nil {
+		nch.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+	conn, err := net.Dial("tcp", net.JoinHostPort(target.Host, strconv.Itoa(int(target.Port))))
+	if err != nil {
+		nch.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+	ch, reqs, err := nch.Accept()
+	if err != nil {
+		conn.Close()
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	go func() {
+		io.Copy(conn, ch)
+		conn.Close()
+	}()
+	io.Copy(ch, conn)
+	ch.Close()
+}
+
+func serveSession(ch ssh.Channel, reqs <-chan *ssh.Request) {
+	defer ch.Close()
+	var cmd *exec.Cmd
+	done := make(chan struct{})
+	for req := range reqs {
+		switch req.Type {
+		case "exec":
+			if cmd != nil {
+				req.Reply(false, nil)
+				continue
+			}
+			var payload struct{ Command string }
+			ssh.Unmarshal(req.Payload, &payload)
+			cmd = exec.Command("/bin/sh", "-c", payload.Command)
+			cmd.Stdin = ch
+			cmd.Stdout = ch
+			cmd.Stderr = ch.Stderr()
+			if err := cmd.Start(); err != nil {
+				req.Reply(false, nil)
+				return
+			}
+			req.Reply(true, nil)
+			go func() {
+				defer close(done)
+				code := 0
+				if err := cmd.Wait(); err != nil {
+					code = 255
+					var exitErr *exec.ExitError
+					if errors.As(err, &exitErr) && exitErr.ExitCode() >= 0 {
+						code = exitErr.ExitCode()
+					}
+				}
+				status := make([]byte, 4)
+				binary.BigEndian.PutUint32(status, uint32(code))
+				ch.SendRequest("exit-status", false, status)
+				ch.Close()
+			}()
+		case "subsystem":
+			var payload struct{ Name string }
+			ssh.Unmarshal(req.Payload, &payload)
+			if payload.Name != "sftp" || cmd != nil {
+				req.Reply(false, nil)
+				continue
+			}
+			req.Reply(true, nil)
+			server, err := sftp.NewServer(ch)
+			if err != nil {
+				return
+			}
+			go func() {
+				server.Serve()
+				server.Close()
+			}()
+		case "signal":
+			if cmd != nil && cmd.Process != nil {
+				cmd.Process.Signal(syscall.SIGKILL)
+			}
+			if req.WantReply {
+				req.Reply(true, nil)
+			}
+		default:
+			if req.WantReply {
+				req.Reply(req.Type == "env" || req.Type == "pty-req", nil)
+			}
+		}
+	}
+	if cmd != nil {
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+		}
+	}
+}
+
+func testClientConfig() *ssh.ClientConfig {
+	return &ssh.ClientConfig{
+		User:            "tester",
+		Auth:            []ssh.AuthMethod{ssh.Password("test")},
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		Timeout:         5 * time.Second,
+	}
+}
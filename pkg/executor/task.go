@@ -2,13 +2,26 @@ package executor
 
 import (
     "context"
+    "encoding/base64"
+    "errors"
+    "fmt"
+    "log"
+    "strings"
+    "time"
+    "unicode/utf8"
     pc "github.com/andrej220/HAM/pkg/processor"
     gp "github.com/andrej220/HAM/pkg/graphproc"
 )
 
+// cleanupTimeout bounds the removal of an upload whose run was killed.
+const cleanupTimeout = 30 * time.Second
+
 type NodeTask struct {
-    Node *gp.Node
-    Exec Executor
+    Node   *gp.Node
+    Exec   Executor
+    Script string // rendered script, Node.Script is used when empty
+    // Defaults apply when the node sets no timeout or output limits.
+    Defaults Limits
 }
 
 func NewNodeTask(node *gp.Node, exec Executor) *NodeTask {
@@ -16,19 +29,289 @@ func NewNodeTask(node *gp.Node, exec Executor) *NodeTask {
 }
 
 func (t *NodeTask) Execute(ctx context.Context) error {
+    if t.Node.Type == gp.NodeTypeFetch {
+        return t.fetch(ctx)
+    }
     // skip objects or empty scripts
     if t.Node.Type == "object" || len(t.Node.Script) == 0 {
         return nil
     }
 
-    out, errOut, err := t.Exec.Run(ctx, t.Node.Script)
+    script := t.Script
+    if script == "" {
+        script = t.Node.Script
+    }
+    t.Node.Start()
+    var upload *PreparedUpload
+    if t.Node.Interpreter != "" {
+        var err error
+        upload, err = PrepareUpload(script, Upload{Interpreter: t.Node.Interpreter, Args: t.Node.Args, Env: t.Node.Env})
+        if err != nil {
+            t.Node.RunError = err.Error()
+            t.Node.Finish(gp.StatusFailed)
+            return err
+        }
+    }
+    if t.Node.Type == gp.NodeTypeCondition {
+        var stdout, stderr []string
+        res, err := t.run(ctx, script, upload, func(stream Stream, line string) {
+            if stream == Stderr {
+                stderr = append(stderr, line)
+                return
+            }
+            stdout = append(stdout, line)
+        })
+        if res != nil {
+            res.Stdout, res.Stderr = stdout, stderr
+        }
+        t.record(res, err)
+        return t.evaluateCondition(ctx, res, err)
+    }
+
+    // post process while the output arrives
+    chain := pc.NewProcessorChain()
+    nodeType := resultType(t.Node.Type)
+    out, processErr := chain.NewStream(nodeType, processorNames(t.Node.PostProcess)...)
+    if processErr != nil {
+        out, _ = chain.NewStream(nodeType)
+    }
+    var stderr []string
+    res, err := t.run(ctx, script, upload, func(stream Stream, line string) {
+        if stream == Stderr {
+            stderr = append(stderr, line)
+            return
+        }
+        out.Add(line)
+    })
+    if res != nil {
+        res.Stderr = stderr
+    }
+    t.record(res, err)
+    if res != nil {
+        t.Node.Stderr = res.Stderr
+    }
     if err != nil {
+        // keep partial output of failed or killed scripts for triage
+        t.Node.Result = pc.Lines(out.Lines(), nodeType)
+        t.Node.Finish(runStatus(ctx, err))
         return err
     }
 
-    // post process
-    chain := pc.NewProcessorChain()
-    t.Node.Stderr = errOut
-    t.Node.Result, _ = chain.Process(out, pc.NodeType(t.Node.Type), t.Node.PostProcess)
+    result, err := out.Result()
+    if processErr == nil {
+        processErr = err
+    }
+    if processErr != nil {
+        // keep the raw output so the collected data is not lost
+        t.Node.ProcessError = processErr.Error()
+        t.Node.Result = pc.Lines(out.Lines(), nodeType)
+        t.Node.Finish(gp.StatusFailed)
+        return nil
+    }
+    t.Node.Result = result
+    t.Node.Finish(gp.StatusOK)
+    return nil
+}
+
+// fetch downloads the file of a fetch node. Text content goes through
+// post_process like script output; binary content is kept as base64.
+func (t *NodeTask) fetch(ctx context.Context) error {
+    t.Node.Start()
+    spec := t.Node.Fetch
+    f, ok := t.Exec.(Fetcher)
+    var err error
+    switch {
+    case spec == nil:
+        err = fmt.Errorf("node %q: no fetch.path", t.Node.ID)
+    case !ok:
+        err = fmt.Errorf("node %q: %T cannot fetch files", t.Node.ID, t.Exec)
+    }
+    if err != nil {
+        t.Node.RunError = err.Error()
+        t.Node.Finish(gp.StatusFailed)
+        return err
+    }
+
+    opts := FetchOptions{Limits: t.limits(), Checksum: spec.ChecksumAlgorithm(), HashOnly: spec.HashOnly}
+    res, err := f.Fetch(ctx, spec.Path, opts)
+    if res != nil {
+        t.Node.Attempts = res.Attempts
+        t.Node.Truncated = res.Truncated
+    }
+    if err != nil {
+        t.Node.RunError = err.Error()
+        t.Node.Finish(runStatus(ctx, err))
+        return err
+    }
+    file := &gp.FileInfo{
+        Path:     spec.Path,
+        Size:     res.Size,
+        Mode:     res.Mode.String(),
+        Checksum: opts.Checksum + ":" + res.Checksum,
+    }
+    if !res.ModTime.IsZero() {
+        mt := res.ModTime.UTC()
+        file.ModTime = &mt
+    }
+    t.Node.File = file
+    if spec.HashOnly {
+        t.Node.Finish(gp.StatusOK)
+        return nil
+    }
+
+    content := res.Content
+    if res.Truncated {
+        // do not let the cut turn text into binary
+        for i := 0; i < utf8.UTFMax && len(content) > 0 && !utf8.Valid(content); i++ {
+            content = content[:len(content)-1]
+        }
+    }
+    if !utf8.Valid(content) {
+        file.Encoding = "base64"
+        t.Node.Result = base64.StdEncoding.EncodeToString(res.Content)
+        t.Node.Finish(gp.StatusOK)
+        return nil
+    }
+    text := string(content)
+    names := processorNames(t.Node.PostProcess)
+    if len(names) == 0 {
+        t.Node.Result = text
+        t.Node.Finish(gp.StatusOK)
+        return nil
+    }
+    lines := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+    result, err := pc.NewProcessorChain().Process(lines, pc.NodeTypeString, names...)
+    if err != nil {
+        // keep the file content so the collected data is not lost
+        t.Node.ProcessError = err.Error()
+        t.Node.Result = text
+        t.Node.Finish(gp.StatusFailed)
+        return nil
+    }
+    t.Node.Result = result
+    t.Node.Finish(gp.StatusOK)
+    return nil
+}
+
+// run passes the output of script to fn, streaming it when the executor
+// supports it. With an upload the prepared command runs instead, and its
+// directory is removed afterwards if the command could not do so itself.
+func (t *NodeTask) run(ctx context.Context, script string, upload *PreparedUpload, fn LineFunc) (*RunResult, error) {
+    if upload != nil {
+        ie, ok := t.Exec.(InputExecutor)
+        if !ok {
+            return nil, fmt.Errorf("node %q: %T cannot upload scripts", t.Node.ID, t.Exec)
+        }
+        res, err := ie.StreamInput(ctx, upload.Command, t.limits(), upload.Input, fn)
+        if _, exited := ExitCode(err); (err != nil && !exited) || (res != nil && res.Truncated) {
+            t.cleanup(ctx, upload)
+        }
+        return res, err
+    }
+    se, ok := t.Exec.(StreamExecutor)
+    if !ok {
+        res, err := t.Exec.Run(ctx, script, t.limits())
+        if res != nil {
+            for _, line := range res.Stdout {
+                fn(Stdout, line)
+            }
+            for _, line := range res.Stderr {
+                fn(Stderr, line)
+            }
+            res.Stdout, res.Stderr = nil, nil
+        }
+        return res, err
+    }
+    return se.Stream(ctx, script, t.limits(), fn)
+}
+
+// cleanup removes the directory of a killed upload. It gets a moment of
+// its own since the run's context is usually what killed it.
+func (t *NodeTask) cleanup(ctx context.Context, upload *PreparedUpload) {
+    ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), cleanupTimeout)
+    defer cancel()
+    if _, err := t.Exec.Run(ctx, upload.Cleanup, Limits{}); err != nil {
+        log.Printf("node %s: remove upload: %v", t.Node.ID, err)
+    }
+}
+
+// limits merges the node limits with the task defaults.
+func (t *NodeTask) limits() Limits {
+    l := t.Defaults
+    if t.Node.Timeout > 0 {
+        l.Timeout = time.Duration(t.Node.Timeout)
+    }
+    if t.Node.MaxOutputBytes > 0 {
+        l.MaxOutputBytes = t.Node.MaxOutputBytes
+    }
+    if t.Node.MaxLines > 0 {
+        l.MaxLines = t.Node.MaxLines
+    }
+    return l
+}
+
+// record copies exit status, attempts and the run error to the node.
+func (t *NodeTask) record(res *RunResult, runErr error) {
+    if res != nil {
+        t.Node.Attempts = res.Attempts
+        t.Node.Truncated = res.Truncated
+    }
+    if code, exited := ExitCode(runErr); exited && (res == nil || !res.Truncated) {
+        t.Node.ExitCode = &code
+    }
+    if runErr != nil {
+        t.Node.RunError = runErr.Error()
+    }
+}
+
+// runStatus classifies a failed run: deadline errors are timeouts,
+// everything else, including non-zero exit codes, is a failure.
+func runStatus(ctx context.Context, err error) gp.NodeStatus {
+    if errors.Is(err, context.DeadlineExceeded) || errors.Is(ctx.Err(), context.DeadlineExceeded) {
+        return gp.StatusTimeout
+    }
+    return gp.StatusFailed
+}
+
+// resultType maps node types without a value shape of their own, such as
+// "exec", to string results.
+func resultType(nodeType string) pc.NodeType {
+    switch nt := pc.NodeType(nodeType); nt {
+    case pc.NodeTypeArray, pc.NodeTypeObject:
+        return nt
+    }
+    return pc.NodeTypeString
+}
+
+// processorNames splits a post_process value such as "key_value,convert".
+func processorNames(postProcess string) []string {
+    var names []string
+    for _, name := range strings.Split(postProcess, ",") {
+        if name = strings.TrimSpace(name); name != "" {
+            names = append(names, name)
+        }
+    }
+    return names
+}
+
+// evaluateCondition records whether the condition node matched. A non-zero
+// exit status is an input to the condition, not a failure.
+func (t *NodeTask) evaluateCondition(ctx context.Context, res *RunResult, runErr error) error {
+    code, exited := ExitCode(runErr)
+    if !exited {
+        t.Node.Finish(runStatus(ctx, runErr))
+        return runErr
+    }
+    t.Node.RunError = ""
+    t.Node.Result = pc.Lines(res.Stdout, pc.NodeTypeString)
+    t.Node.Stderr = res.Stderr
+    met, err := t.Node.Condition.Evaluate(res.Stdout, code)
+    if err != nil {
+        t.Node.ProcessError = err.Error()
+        t.Node.Finish(gp.StatusFailed)
+        return err
+    }
+    t.Node.ConditionMet = &met
+    t.Node.Finish(gp.StatusOK)
     return nil
 }
@@ -0,0 +1,190 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	gp "github.com/andrej220/HAM/pkg/graphproc"
+)
+
+type fakeExecutor struct {
+	res *RunResult
+	err error
+}
+
+func (f *fakeExecutor) Run(ctx context.Context, script string, limits Limits) (*RunResult, error) {
+	return f.res, f.err
+}
+
+func TestNodeTaskRecordsOutcome(t *testing.T) {
+	tests := []struct {
+		name     string
+		node     *gp.Node
+		exec     *fakeExecutor
+		status   gp.NodeStatus
+		exitCode *int
+		wantErr  bool
+	}{
+		{
+			name:     "ok",
+			node:     &gp.Node{ID: "n", Type: "string", Script: "uname -r"},
+			exec:     &fakeExecutor{res: &RunResult{Stdout: []string{"6.1.0"}, Attempts: 1}},
+			status:   gp.StatusOK,
+			exitCode: intPtr(0),
+		},
+		{
+			name:   "processor error",
+			node:   &gp.Node{ID: "n", Type: "string", Script: "echo x", PostProcess: "json"},
+			exec:   &fakeExecutor{res: &RunResult{Stdout: []string{"x"}, Attempts: 1}},
+			status: gp.StatusFailed, exitCode: intPtr(0),
+		},
+		{
+			name:    "transport error",
+			node:    &gp.Node{ID: "n", Script: "true"},
+			exec:    &fakeExecutor{res: &RunResult{Attempts: 3}, err: errors.New("connection reset")},
+			status:  gp.StatusFailed,
+			wantErr: true,
+		},
+		{
+			name:    "timeout",
+			node:    &gp.Node{ID: "n", Script: "sleep 100"},
+			exec:    &fakeExecutor{res: &RunResult{Attempts: 1}, err: context.DeadlineExceeded},
+			status:  gp.StatusTimeout,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := NewNodeTask(tt.node, tt.exec).Execute(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Execute error = %v, wantErr %v", err, tt.wantErr)
+			}
+			n := tt.node
+			if n.Status != tt.status {
+				t.Errorf("status = %q, want %q", n.Status, tt.status)
+			}
+			if (n.ExitCode == nil) != (tt.exitCode == nil) || (n.ExitCode != nil && *n.ExitCode != *tt.exitCode) {
+				t.Errorf("exit code = %v, want %v", n.ExitCode, tt.exitCode)
+			}
+			if n.Attempts != tt.exec.res.Attempts {
+				t.Errorf("attempts = %d, want %d", n.Attempts, tt.exec.res.Attempts)
+			}
+			if n.StartedAt == nil || n.FinishedAt == nil {
+				t.Error("start and finish times not recorded")
+			}
+			if tt.wantErr && n.RunError == "" {
+				t.Error("run error not recorded")
+			}
+			if tt.name == "processor error" && (n.ProcessError == "" || n.Result != "x") {
+				t.Errorf("process error %q, result %v", n.ProcessError, n.Result)
+			}
+		})
+	}
+}
+
+func TestSSHExecutorExitCode(t *testing.T) {
+	srv := startTestSSHServer(t)
+	pool := NewConnPool(PoolConfig{})
+	defer pool.Close()
+	client, err := pool.Get(context.Background(), srv.Addr, testClientConfig())
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	defer client.Close()
+
+	node := &gp.Node{ID: "n", Script: "echo oops >&2; exit 3"}
+	if err := NewNodeTask(node, NewSSHExecutor(client)).Execute(context.Background()); err == nil {
+		t.Fatal("expected error for non-zero exit")
+	}
+	if node.ExitCode == nil || *node.ExitCode != 3 || node.Status != gp.StatusFailed {
+		t.Errorf("exit code %v, status %q", node.ExitCode, node.Status)
+	}
+	if len(node.Stderr) != 1 || node.Stderr[0] != "oops" {
+		t.Errorf("stderr = %v", node.Stderr)
+	}
+}
+
+func intPtr(i int) *int { return &i }
+
+func TestSSHExecutorLimits(t *testing.T) {
+	srv := startTestSSHServer(t)
+	pool := NewConnPool(PoolConfig{})
+	defer pool.Close()
+	client, err := pool.Get(context.Background(), srv.Addr, testClientConfig())
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	defer client.Close()
+	exec := NewSSHExecutor(client)
+
+	t.Run("timeout", func(t *testing.T) {
+		node := &gp.Node{ID: "n", Script: "echo started; sleep 10", Timeout: gp.Duration(300 * time.Millisecond)}
+		start := time.Now()
+		if err := NewNodeTask(node, exec).Execute(context.Background()); err == nil {
+			t.Fatal("expected timeout error")
+		}
+		if d := time.Since(start); d > 5*time.Second {
+			t.Errorf("script was not killed, took %s", d)
+		}
+		if node.Status != gp.StatusTimeout {
+			t.Errorf("status = %q, want timeout", node.Status)
+		}
+		if node.Result != "started" {
+			t.Errorf("partial output = %v", node.Result)
+		}
+	})
+
+	t.Run("max lines", func(t *testing.T) {
+		node := &gp.Node{ID: "n", Type: "array", Script: "yes", MaxLines: 5}
+		if err := NewNodeTask(node, exec).Execute(context.Background()); err != nil {
+			t.Fatalf("Execute: %v", err)
+		}
+		lines, _ := node.Result.([]any)
+		if !node.Truncated || len(lines) != 5 || node.Status != gp.StatusOK {
+			t.Errorf("truncated %v, %d lines, status %q", node.Truncated, len(lines), node.Status)
+		}
+	})
+
+	t.Run("max bytes", func(t *testing.T) {
+		node := &gp.Node{ID: "n", Script: "echo 0123456789; echo abc", MaxOutputBytes: 14}
+		if err := NewNodeTask(node, exec).Execute(context.Background()); err != nil {
+			t.Fatalf("Execute: %v", err)
+		}
+		if !node.Truncated || node.Result != "0123456789\nabc" {
+			t.Errorf("truncated %v, result %q", node.Truncated, node.Result)
+		}
+	})
+}
+
+func TestSSHExecutorStreamsConcurrently(t *testing.T) {
+	srv := startTestSSHServer(t)
+	pool := NewConnPool(PoolConfig{})
+	defer pool.Close()
+	client, err := pool.Get(context.Background(), srv.Addr, testClientConfig())
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	defer client.Close()
+
+	// more stderr than the channel window before any stdout; reading the
+	// streams one after the other would block forever
+	script := "i=0; while [ $i -lt 40000 ]; do echo 0123456789012345678901234567890123456789012345678901234567890123456789 >&2; i=$((i+1)); done; echo done"
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	var stdout, stderr int
+	res, err := NewSSHExecutor(client).Stream(ctx, script, Limits{}, func(stream Stream, line string) {
+		if stream == Stderr {
+			stderr++
+			return
+		}
+		stdout++
+	})
+	if err != nil {
+		t.Fatalf("Stream: %v", err)
+	}
+	if stdout != 1 || stderr != 40000 || res.Stdout != nil {
+		t.Errorf("stdout %d lines, stderr %d lines, buffered %d", stdout, stderr, len(res.Stdout))
+	}
+}
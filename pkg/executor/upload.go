@@ -0,0 +1,91 @@
+package executor
+
+import (
+    "bytes"
+    "encoding/base64"
+    "fmt"
+    "sort"
+    "strings"
+
+    gp "github.com/andrej220/HAM/pkg/graphproc"
+)
+
+// interpreters maps the interpreters of graphproc to the command that runs
+// a script file and the file name it needs.
+var interpreters = map[string]struct{ command, file string }{
+    gp.InterpreterSh:         {"sh", "script.sh"},
+    gp.InterpreterBash:       {"bash", "script.sh"},
+    gp.InterpreterPython3:    {"python3", "script.py"},
+    gp.InterpreterPowerShell: {"pwsh -NoProfile -NonInteractive -File", "script.ps1"},
+}
+
+// uploadLineLength is the width of the base64 lines sent on stdin, well
+// below the line limit of terminals in canonical mode.
+const uploadLineLength = 76
+
+// Upload describes a script that is written to a file on the host and run
+// with an interpreter instead of being passed to the login shell.
+type Upload struct {
+    Interpreter string // see graphproc.Interpreter*
+    Args        []string
+    Env         map[string]string
+}
+
+// PreparedUpload is a script upload ready to run.
+type PreparedUpload struct {
+    Command string // writes the script and runs it, Input goes on its stdin
+    Input   []byte // the script, base64 encoded in short lines
+    Cleanup string // removes the directory if Command was killed
+}
+
+// PrepareUpload returns the command that reads script from stdin into a
+// new temporary directory on the host, runs it with the interpreter and
+// removes the directory again. Only the script's size is part of the
+// command, so neither argv limits nor ps apply to the body. The script
+// arrives base64 encoded in lines, which passes through containers and
+// the terminal of su alike, and is checked for its size before it runs.
+// The exit status is that of the script; the script's own stdin is
+// empty.
+func PrepareUpload(script string, u Upload) (*PreparedUpload, error) {
+    interp, ok := interpreters[u.Interpreter]
+    if !ok {
+        return nil, fmt.Errorf("unknown interpreter %q", u.Interpreter)
+    }
+
+    encoded := base64.StdEncoding.EncodeToString([]byte(script))
+    var input bytes.Buffer
+    for len(encoded) > uploadLineLength {
+        input.WriteString(encoded[:uploadLineLength] + "\n")
+        encoded = encoded[uploadLineLength:]
+    }
+    input.WriteString(encoded + "\n")
+
+    dir := `"${TMPDIR:-/tmp}/ham.` + newMarker() + `"`
+    file := `"$d/` + interp.file + `"`
+    var b strings.Builder
+    b.WriteString("d=" + dir + "\n")
+    b.WriteString(`mkdir -m 700 "$d" || exit 125` + "\n")
+    b.WriteString(`trap 'rm -rf "$d"' EXIT` + "\n")
+    b.WriteString(`trap 'exit 143' HUP INT TERM` + "\n")
+    fmt.Fprintf(&b, "head -c %d | base64 -d > %s || exit 125\n", input.Len(), file)
+    fmt.Fprintf(&b, "[ $(wc -c < %s) -eq %d ] || { echo 'upload incomplete' >&2; exit 125; }\n", file, len(script))
+
+    names := make([]string, 0, len(u.Env))
+    for name := range u.Env {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    for _, name := range names {
+        b.WriteString(name + "=" + shellQuote(u.Env[name]) + " ")
+    }
+    b.WriteString(interp.command + " " + file)
+    for _, arg := range u.Args {
+        b.WriteString(" " + shellQuote(arg))
+    }
+    b.WriteString(" < /dev/null")
+    return &PreparedUpload{
+        Command: b.String(),
+        Input:   input.Bytes(),
+        Cleanup: "rm -rf " + dir,
+    }, nil
+}
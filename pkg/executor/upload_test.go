@@ -0,0 +1,172 @@
+package executor
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	gp "github.com/andrej220/HAM/pkg/graphproc"
+)
+
+// runUpload runs a prepared upload with e and returns its output.
+func runUpload(t *testing.T, e InputExecutor, up *PreparedUpload) (*RunResult, error) {
+	t.Helper()
+	var stdout, stderr []string
+	res, err := e.StreamInput(context.Background(), up.Command, Limits{}, up.Input, func(stream Stream, line string) {
+		if stream == Stderr {
+			stderr = append(stderr, line)
+			return
+		}
+		stdout = append(stdout, line)
+	})
+	res.Stdout, res.Stderr = stdout, stderr
+	return res, err
+}
+
+func TestPrepareUpload(t *testing.T) {
+	tmp := t.TempDir()
+	e := NewLocalExecutor()
+	e.Env = []string{"TMPDIR=" + tmp}
+
+	script := "#!/bin/bash\nset -e\necho \"$1|$2|$GREETING\"\nprintf 'it'\"'\"'s\\n'\nexit 3\n"
+	up, err := PrepareUpload(script, Upload{
+		Interpreter: gp.InterpreterBash,
+		Args:        []string{"a b", "$HOME"},
+		Env:         map[string]string{"GREETING": "hi 'there'"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(up.Command, "GREETING\"") || strings.Contains(up.Command, "set -e") {
+		t.Errorf("script body in the command: %q", up.Command)
+	}
+	res, err := runUpload(t, e, up)
+	if code, ok := ExitCode(err); !ok || code != 3 {
+		t.Fatalf("exit code %d, %v (err %v)", code, ok, err)
+	}
+	if want := []string{"a b|$HOME|hi 'there'", "it's"}; !reflect.DeepEqual(res.Stdout, want) {
+		t.Errorf("stdout = %q, want %q", res.Stdout, want)
+	}
+	entries, _ := os.ReadDir(tmp)
+	if len(entries) != 0 {
+		t.Errorf("temporary files left behind: %v", entries)
+	}
+
+	if _, err := PrepareUpload("x", Upload{Interpreter: "perl"}); err == nil {
+		t.Error("expected an error for an unknown interpreter")
+	}
+}
+
+// largeScript is beyond the 128 KiB a single command line argument may
+// have on Linux.
+func largeScript() string {
+	var script strings.Builder
+	script.WriteString("#!/bin/sh\n")
+	for script.Len() < 300<<10 {
+		script.WriteString("# padding to make the script larger than one argument may be\n")
+	}
+	script.WriteString("echo done\n")
+	return script.String()
+}
+
+func TestPrepareUploadLarge(t *testing.T) {
+	tmp := t.TempDir()
+	local := NewLocalExecutor()
+	local.Env = []string{"TMPDIR=" + tmp}
+	sudo := fakeSudoExecutor(t)
+	sudo.Env = append(sudo.Env, "TMPDIR="+tmp)
+	become, err := NewBecomeExecutor(sudo, Become{Password: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := startTestSSHServer(t)
+	pool := NewConnPool(PoolConfig{})
+	defer pool.Close()
+	client, err := pool.Get(context.Background(), srv.Addr, testClientConfig())
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	defer client.Close()
+
+	up, err := PrepareUpload(largeScript(), Upload{Interpreter: gp.InterpreterSh})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(up.Command) > 1024 {
+		t.Errorf("command is %d bytes", len(up.Command))
+	}
+	for name, e := range map[string]InputExecutor{
+		"local":  local,
+		"become": become,
+		"ssh":    NewSSHExecutor(client),
+	} {
+		res, err := runUpload(t, e, up)
+		if err != nil {
+			t.Fatalf("%s: %v (stderr %q)", name, err, res.Stderr)
+		}
+		if want := []string{"done"}; !reflect.DeepEqual(res.Stdout, want) {
+			t.Errorf("%s: stdout = %q, want %q", name, res.Stdout, want)
+		}
+	}
+	entries, _ := os.ReadDir(tmp)
+	if len(entries) != 0 {
+		t.Errorf("temporary files left behind: %v", entries)
+	}
+}
+
+func TestPrepareUploadIncomplete(t *testing.T) {
+	tmp := t.TempDir()
+	e := NewLocalExecutor()
+	e.Env = []string{"TMPDIR=" + tmp}
+
+	up, err := PrepareUpload("echo ran\n", Upload{Interpreter: gp.InterpreterSh})
+	if err != nil {
+		t.Fatal(err)
+	}
+	up.Input = up.Input[:len(up.Input)/2]
+	res, err := runUpload(t, e, up)
+	if code, ok := ExitCode(err); !ok || code != 125 {
+		t.Fatalf("exit code %d, %v (err %v)", code, ok, err)
+	}
+	if len(res.Stdout) != 0 {
+		t.Errorf("truncated script ran: %q", res.Stdout)
+	}
+}
+
+func TestNodeTaskRemovesKilledUpload(t *testing.T) {
+	tmp := t.TempDir()
+	e := NewLocalExecutor()
+	e.Env = []string{"TMPDIR=" + tmp}
+
+	node := &gp.Node{ID: "slow", Type: "string", Interpreter: gp.InterpreterSh,
+		Script: "sleep 30\n", Timeout: gp.Duration(200 * time.Millisecond)}
+	if err := NewNodeTask(node, e).Execute(context.Background()); err == nil {
+		t.Fatal("expected the script to be killed")
+	}
+	if node.Status != gp.StatusTimeout {
+		t.Errorf("status = %q, want %q", node.Status, gp.StatusTimeout)
+	}
+	entries, _ := os.ReadDir(tmp)
+	if len(entries) != 0 {
+		t.Errorf("temporary files left behind: %v", entries)
+	}
+}
+
+func TestNodeTaskUploadsPython(t *testing.T) {
+	if _, err := exec.LookPath("python3"); err != nil {
+		t.Skip("python3 not installed")
+	}
+	node := &gp.Node{ID: "py", Type: "array", Interpreter: gp.InterpreterPython3,
+		Script: "import sys\nfor a in sys.argv[1:]:\n    print(a.upper())\n", Args: []string{"x", "y"}}
+	if err := NewNodeTask(node, NewLocalExecutor()).Execute(context.Background()); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if want := []any{"X", "Y"}; !reflect.DeepEqual(node.Result, want) {
+		t.Errorf("result = %v, want %v", node.Result, want)
+	}
+}
@@ -0,0 +1,47 @@
+package graphproc
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// Privilege escalation methods.
+const (
+	BecomeSudo = "sudo"
+	BecomeSu   = "su"
+)
+
+var becomeUser = regexp.MustCompile(`^[a-zA-Z0-9_][a-zA-Z0-9_.-]*$`)
+
+// Become runs the script of a node as another user. The password is taken
+// from the credential named here, or from the host's credential.
+type Become struct {
+	Method           string `json:"method,omitempty"`           // sudo (default) or su
+	User             string `json:"user,omitempty"`             // root if unset
+	TTY              bool   `json:"tty,omitempty"`              // run sudo on a pseudo-terminal; su always has one
+	CredentialSource string `json:"credentialSource,omitempty"` // file | env | agent | vault
+	CredentialRef    string `json:"credentialRef,omitempty"`
+}
+
+// validateBecome checks the become settings of a node.
+func (n *Node) validateBecome() error {
+	b := n.Become
+	if b == nil {
+		return nil
+	}
+	if n.IsLocal() || n.Type == NodeTypeFetch {
+		return fmt.Errorf("node %q: become is not supported for %s nodes", n.ID, n.Type)
+	}
+	switch b.Method {
+	case "", BecomeSudo, BecomeSu:
+	default:
+		return fmt.Errorf("node %q: unknown become method %q", n.ID, b.Method)
+	}
+	if b.User != "" && !becomeUser.MatchString(b.User) {
+		return fmt.Errorf("node %q: invalid become user %q", n.ID, b.User)
+	}
+	if b.CredentialRef != "" && b.CredentialSource == "" {
+		return fmt.Errorf("node %q: become.credentialRef needs a credentialSource", n.ID)
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package graphproc
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateBecome(t *testing.T) {
+	tests := []struct {
+		name string
+		node *Node
+		err  string
+	}{
+		{"sudo", &Node{ID: "n", Type: "exec", Script: "dmidecode", Become: &Become{}}, ""},
+		{"su", &Node{ID: "n", Script: "id", Become: &Become{Method: BecomeSu, User: "postgres"}}, ""},
+		{"method", &Node{ID: "n", Script: "id", Become: &Become{Method: "doas"}}, "unknown become method"},
+		{"user", &Node{ID: "n", Script: "id", Become: &Become{User: "root; rm"}}, "invalid become user"},
+		{"fetch", &Node{ID: "n", Type: NodeTypeFetch, Fetch: &Fetch{Path: "/etc/shadow"}, Become: &Become{}}, "not supported"},
+		{"ref", &Node{ID: "n", Script: "id", Become: &Become{CredentialRef: "admin"}}, "needs a credentialSource"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateNode(tt.node)
+			if tt.err == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("err = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
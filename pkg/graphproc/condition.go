@@ -0,0 +1,74 @@
+package graphproc
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const NodeTypeCondition = "condition"
+
+// NodeStatus describes the outcome of a node in the stored graph.
+type NodeStatus string
+
+const (
+	// StatusOK marks nodes that ran and produced a result.
+	StatusOK NodeStatus = "ok"
+	// StatusFailed marks nodes whose script exited non-zero, could not be
+	// run, or whose output could not be processed.
+	StatusFailed NodeStatus = "failed"
+	// StatusTimeout marks nodes that did not finish before their deadline.
+	StatusTimeout NodeStatus = "timeout"
+	// StatusSkipped marks nodes gated off by a condition ("not applicable").
+	StatusSkipped NodeStatus = "skipped"
+)
+
+// Condition decides whether the children and dependents of a condition
+// node run. All fields that are set must match; with none set the script
+// must exit with status 0.
+type Condition struct {
+	Regex    string  `json:"regex,omitempty"`     // output matches the regular expression
+	Equals   *string `json:"equals,omitempty"`    // trimmed output equals the value
+	ExitCode *int    `json:"exit_code,omitempty"` // script exits with this status
+	Negate   bool    `json:"negate,omitempty"`    // invert the result
+}
+
+// Validate checks that the regular expression compiles.
+func (c *Condition) Validate() error {
+	if c == nil || c.Regex == "" {
+		return nil
+	}
+	if _, err := regexp.Compile(c.Regex); err != nil {
+		return fmt.Errorf("invalid condition regex: %w", err)
+	}
+	return nil
+}
+
+// Evaluate matches the output lines and exit status of the condition script.
+func (c *Condition) Evaluate(lines []string, exitCode int) (bool, error) {
+	if c == nil {
+		return exitCode == 0, nil
+	}
+
+	output := strings.Join(lines, "\n")
+	met := true
+	if c.ExitCode != nil {
+		met = met && exitCode == *c.ExitCode
+	} else if c.Regex == "" && c.Equals == nil {
+		met = exitCode == 0
+	}
+	if c.Equals != nil {
+		met = met && strings.TrimSpace(output) == *c.Equals
+	}
+	if c.Regex != "" {
+		re, err := regexp.Compile(c.Regex)
+		if err != nil {
+			return false, fmt.Errorf("invalid condition regex: %w", err)
+		}
+		met = met && re.MatchString(output)
+	}
+	if c.Negate {
+		met = !met
+	}
+	return met, nil
+}
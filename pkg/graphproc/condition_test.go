@@ -0,0 +1,76 @@
+package graphproc
+
+import (
+	"context"
+	"testing"
+)
+
+func TestConditionEvaluate(t *testing.T) {
+	str := func(s string) *string { return &s }
+	code := func(i int) *int { return &i }
+	tests := []struct {
+		name  string
+		cond  *Condition
+		lines []string
+		exit  int
+		want  bool
+	}{
+		{"nil condition exit 0", nil, nil, 0, true},
+		{"nil condition exit 1", nil, nil, 1, false},
+		{"regex match", &Condition{Regex: `^ID=(debian|ubuntu)$`}, []string{"NAME=x", "ID=debian"}, 0, false},
+		{"regex multiline", &Condition{Regex: `(?m)^ID=(debian|ubuntu)$`}, []string{"NAME=x", "ID=debian"}, 0, true},
+		{"equals trimmed", &Condition{Equals: str("yes")}, []string{"  yes "}, 0, true},
+		{"exit code", &Condition{ExitCode: code(3)}, nil, 3, true},
+		{"regex ignores exit status", &Condition{Regex: "found"}, []string{"found"}, 1, true},
+		{"negate", &Condition{Equals: str("yes"), Negate: true}, []string{"no"}, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.cond.Evaluate(tt.lines, tt.exit)
+			if err != nil {
+				t.Fatalf("Evaluate: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExecuteSkipsGatedNodes(t *testing.T) {
+	g := &Graph{Root: &Node{
+		ID: "root",
+		Children: []*Node{
+			{ID: "is_docker", Type: NodeTypeCondition, Script: "command -v docker", Children: []*Node{
+				{ID: "containers", Type: "array", Script: "docker ps"},
+			}},
+			{ID: "summary", Type: "string", Script: "echo", DependsOn: []string{"containers"}},
+			{ID: "uptime", Type: "string", Script: "uptime"},
+		},
+	}}
+
+	ran := map[string]bool{}
+	err := g.Execute(context.Background(), 1, func(_ context.Context, n *Node) error {
+		ran[n.ID] = true
+		if n.Type == NodeTypeCondition {
+			met := false
+			n.ConditionMet = &met
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+
+	for _, id := range []string{"containers", "summary"} {
+		if ran[id] {
+			t.Errorf("%s ran behind a failed condition", id)
+		}
+		if s := g.NodeByID(id).Status; s != StatusSkipped {
+			t.Errorf("%s status = %q, want skipped", id, s)
+		}
+	}
+	if !ran["uptime"] {
+		t.Error("independent node did not run")
+	}
+}
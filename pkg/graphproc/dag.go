@@ -0,0 +1,348 @@
+package graphproc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// NodeFunc is called once per node by Graph.Execute.
+type NodeFunc func(ctx context.Context, node *Node) error
+
+// dependencies returns the IDs a node waits for: its parent in the tree,
+// the inputs of a transform or aggregate node and everything listed in
+// depends_on. Local nodes run after their children, so a child waits for
+// the nearest ancestor that is not local instead.
+func dependencies(node *Node, parent map[*Node]*Node) []string {
+	deps := make([]string, 0, len(node.DependsOn)+1)
+	p := parent[node]
+	for p != nil && p.IsLocal() {
+		p = parent[p]
+	}
+	if p != nil {
+		deps = append(deps, p.ID)
+	}
+	if node.IsLocal() {
+		deps = append(deps, node.inputs()...)
+	}
+	return append(deps, node.DependsOn...)
+}
+
+// Nodes returns all nodes of the graph in DFS order.
+func (g *Graph) Nodes() []*Node {
+	var nodes []*Node
+	for n := range g.NodeGenerator() {
+		nodes = append(nodes, n)
+	}
+	return nodes
+}
+
+// NodeByID returns the node with the given ID, or nil.
+func (g *Graph) NodeByID(id string) *Node {
+	for _, n := range g.Nodes() {
+		if n.ID == id {
+			return n
+		}
+	}
+	return nil
+}
+
+type graphIndex struct {
+	nodes  []*Node
+	byID   map[string]*Node
+	parent map[*Node]*Node
+}
+
+func (g *Graph) index() (*graphIndex, error) {
+	nodes := g.Nodes()
+	idx := &graphIndex{
+		nodes:  nodes,
+		byID:   make(map[string]*Node, len(nodes)),
+		parent: make(map[*Node]*Node, len(nodes)),
+	}
+	for _, n := range nodes {
+		if _, dup := idx.byID[n.ID]; dup {
+			return nil, fmt.Errorf("duplicate node id %q", n.ID)
+		}
+		idx.byID[n.ID] = n
+		for _, c := range n.Children {
+			idx.parent[c] = n
+		}
+	}
+	return idx, nil
+}
+
+// blocked reports whether a dependency of node was skipped or failed, or
+// is a condition that did not match, in which case node must be skipped too.
+// Aggregate nodes are not blocked by their inputs; skipped and failed
+// inputs are left out of the aggregated value instead.
+func (idx *graphIndex) blocked(node *Node) bool {
+	optional := make(map[string]bool)
+	if node.Type == NodeTypeAggregate {
+		for _, id := range node.inputs() {
+			optional[id] = true
+		}
+	}
+	for _, id := range dependencies(node, idx.parent) {
+		dep := idx.byID[id]
+		if dep == nil || optional[id] {
+			continue
+		}
+		if dep.Status == StatusSkipped || dep.Status.failed() {
+			return true
+		}
+		if dep.Type == NodeTypeCondition && (dep.ConditionMet == nil || !*dep.ConditionMet) {
+			return true
+		}
+	}
+	return false
+}
+
+// skipUnstarted marks the nodes that never started as skipped.
+func (idx *graphIndex) skipUnstarted() {
+	for _, n := range idx.nodes {
+		if n.Status == "" && n.StartedAt == nil {
+			n.Status = StatusSkipped
+		}
+	}
+}
+
+// Levels orders the nodes topologically. Nodes in the same level do not
+// depend on each other and may run in parallel; every node comes after
+// its parent and its depends_on nodes.
+func (g *Graph) Levels() ([][]*Node, error) {
+	idx, err := g.index()
+	if err != nil {
+		return nil, err
+	}
+	nodes := idx.nodes
+
+	indegree := make(map[*Node]int, len(nodes))
+	dependents := make(map[*Node][]*Node, len(nodes))
+	for _, n := range nodes {
+		for _, id := range dependencies(n, idx.parent) {
+			dep, ok := idx.byID[id]
+			if !ok {
+				return nil, fmt.Errorf("node %q depends on unknown node %q", n.ID, id)
+			}
+			if dep == n {
+				return nil, fmt.Errorf("node %q depends on itself", n.ID)
+			}
+			indegree[n]++
+			dependents[dep] = append(dependents[dep], n)
+		}
+	}
+
+	var levels [][]*Node
+	var current []*Node
+	for _, n := range nodes {
+		if indegree[n] == 0 {
+			current = append(current, n)
+		}
+	}
+	placed := 0
+	for len(current) > 0 {
+		levels = append(levels, current)
+		placed += len(current)
+		var next []*Node
+		for _, n := range current {
+			for _, d := range dependents[n] {
+				indegree[d]--
+				if indegree[d] == 0 {
+					next = append(next, d)
+				}
+			}
+		}
+		current = next
+	}
+	if placed != len(nodes) {
+		return nil, fmt.Errorf("graph contains dependency cycles")
+	}
+	return levels, nil
+}
+
+// Execute runs fn for every node in topological order. Levels run one
+// after another; nodes within a level run concurrently, at most workers
+// at a time. Nodes behind a condition that did not match, or behind a
+// skipped or failed node, are marked skipped instead of run. Transform and
+// aggregate nodes are evaluated here from their inputs and never passed
+// to fn.
+//
+// The failure policy of the script decides when execution stops: with
+// fail_fast the first error, of a script or of a transform or aggregate
+// node, cancels the running level and is returned; otherwise failed nodes
+// are recorded and execution goes on until the max_failures threshold is
+// exceeded. Nodes that never started because execution stopped early are
+// marked skipped. Graph.Status is set either way.
+func (g *Graph) Execute(ctx context.Context, workers int, fn NodeFunc) (err error) {
+	levels, err := g.Levels()
+	if err != nil {
+		return err
+	}
+	idx, err := g.index()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			idx.skipUnstarted()
+		}
+		g.Status = idx.jobStatus(err)
+		if err != nil && g.Error == "" {
+			g.Error = err.Error()
+		}
+	}()
+
+	limit := g.failurePolicy().limit()
+	failures := 0
+	for _, level := range levels {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		eg, egCtx := &errgroup.Group{}, ctx
+		if limit == 0 {
+			// fail fast: the first error cancels the rest of the level
+			eg, egCtx = errgroup.WithContext(ctx)
+		}
+		if workers > 0 {
+			eg.SetLimit(workers)
+		}
+		for _, node := range level {
+			if idx.blocked(node) {
+				node.Status = StatusSkipped
+				continue
+			}
+			if node.IsLocal() {
+				node.Start()
+				if err := idx.evaluateLocal(node); err != nil {
+					node.ProcessError = err.Error()
+					node.Finish(StatusFailed)
+					if limit == 0 {
+						// fail fast: stop the level like a failed script
+						eg.Go(func() error { return err })
+					}
+					continue
+				}
+				node.Finish(StatusOK)
+				continue
+			}
+			eg.Go(func() error {
+				err := fn(egCtx, node)
+				if limit == 0 && errors.Is(err, context.Canceled) && egCtx.Err() != nil && ctx.Err() == nil {
+					// canceled because a sibling failed, not a failure of its own
+					node.Finish(StatusSkipped)
+					return nil
+				}
+				if err != nil && !node.Status.failed() {
+					node.RunError = err.Error()
+					node.Finish(StatusFailed)
+				}
+				if limit == 0 {
+					return err
+				}
+				return nil
+			})
+		}
+		levelErr := eg.Wait()
+		for _, n := range level {
+			if n.Status.failed() {
+				failures++
+			}
+		}
+		if levelErr != nil {
+			return levelErr
+		}
+		if limit >= 0 && failures > limit {
+			return fmt.Errorf("%w: %d failed, %d tolerated", ErrTooManyFailures, failures, limit)
+		}
+	}
+	return nil
+}
+
+// RenderScript expands references to results of other nodes in the node's
+// script. The script is a text/template with these functions:
+//
+//	{{ result "distro" }}      result of node "distro" as text, see ResultText
+//	{{ line "distro" 0 }}      a single line, or list element, of that text
+//	{{ result "distro" | quote }}  the value quoted for the shell
+//
+// Referenced nodes must be dependencies of the node (its ancestors or
+// depends_on entries) so they have finished before it runs.
+func (g *Graph) RenderScript(node *Node) (string, error) {
+	if !strings.Contains(node.Script, "{{") {
+		return node.Script, nil
+	}
+
+	allowed := g.upstream(node)
+	lookup := func(id string) (*Node, error) {
+		n, ok := allowed[id]
+		if !ok {
+			return nil, fmt.Errorf("node %q is not a dependency of %q", id, node.ID)
+		}
+		return n, nil
+	}
+	funcs := template.FuncMap{
+		"result": func(id string) (string, error) {
+			n, err := lookup(id)
+			if err != nil {
+				return "", err
+			}
+			return ResultText(n.Result), nil
+		},
+		"line": func(id string, i int) (string, error) {
+			n, err := lookup(id)
+			if err != nil {
+				return "", err
+			}
+			lines := resultLines(n.Result)
+			if i < 0 || i >= len(lines) {
+				return "", nil
+			}
+			return lines[i], nil
+		},
+		"quote": shellQuote,
+	}
+
+	tmpl, err := template.New(node.ID).Funcs(funcs).Option("missingkey=error").Parse(node.Script)
+	if err != nil {
+		return "", fmt.Errorf("node %q: parse script: %w", node.ID, err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, nil); err != nil {
+		return "", fmt.Errorf("node %q: render script: %w", node.ID, err)
+	}
+	return buf.String(), nil
+}
+
+// upstream returns every node that must finish before node runs.
+func (g *Graph) upstream(node *Node) map[string]*Node {
+	seen := make(map[string]*Node)
+	idx, err := g.index()
+	if err != nil {
+		return seen
+	}
+
+	var visit func(n *Node)
+	visit = func(n *Node) {
+		for _, id := range dependencies(n, idx.parent) {
+			dep, ok := idx.byID[id]
+			if !ok || seen[id] != nil {
+				continue
+			}
+			seen[id] = dep
+			visit(dep)
+		}
+	}
+	visit(node)
+	return seen
+}
+
+// shellQuote wraps s in single quotes for POSIX shells.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
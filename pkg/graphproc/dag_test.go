@@ -0,0 +1,207 @@
+package graphproc
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func testGraph() *Graph {
+	return &Graph{Root: &Node{
+		ID: "root",
+		Children: []*Node{
+			{ID: "distro", Type: "string", Script: "cat /etc/os-release"},
+			{ID: "packages", Type: "array", Script: `pkgs {{ result "distro" | quote }}`, DependsOn: []string{"distro"}},
+			{ID: "system", Type: "object", Children: []*Node{
+				{ID: "cpu", Type: "string", Script: "lscpu"},
+			}},
+		},
+	}}
+}
+
+func levelIDs(levels [][]*Node) [][]string {
+	out := make([][]string, len(levels))
+	for i, l := range levels {
+		for _, n := range l {
+			out[i] = append(out[i], n.ID)
+		}
+	}
+	return out
+}
+
+func TestLevels(t *testing.T) {
+	levels, err := testGraph().Levels()
+	if err != nil {
+		t.Fatalf("Levels: %v", err)
+	}
+	got := levelIDs(levels)
+	want := [][]string{{"root"}, {"distro", "system"}, {"packages", "cpu"}}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if strings.Join(got[i], ",") != strings.Join(want[i], ",") {
+			t.Errorf("level %d: got %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestLevelsErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		root *Node
+	}{
+		{"unknown dependency", &Node{ID: "a", DependsOn: []string{"missing"}}},
+		{"duplicate id", &Node{ID: "a", Children: []*Node{{ID: "a"}}}},
+		{"cycle", &Node{ID: "r", Children: []*Node{
+			{ID: "a", DependsOn: []string{"b"}},
+			{ID: "b", DependsOn: []string{"a"}},
+		}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := (&Graph{Root: tt.root}).Levels(); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+func TestExecuteRunsDependenciesFirst(t *testing.T) {
+	g := testGraph()
+	var mu sync.Mutex
+	done := map[string]bool{}
+
+	err := g.Execute(context.Background(), 4, func(_ context.Context, n *Node) error {
+		if n.ID == "packages" {
+			mu.Lock()
+			defer mu.Unlock()
+			if !done["distro"] {
+				t.Error("packages ran before distro")
+			}
+			script, err := g.RenderScript(n)
+			if err != nil {
+				return err
+			}
+			if script != `pkgs 'debian'\''s'` {
+				t.Errorf("rendered script: %q", script)
+			}
+			return nil
+		}
+		if n.ID == "distro" {
+			n.Result = "debian's"
+		}
+		mu.Lock()
+		done[n.ID] = true
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+}
+
+func TestExecuteFailFastSkipsCanceledSiblings(t *testing.T) {
+	g := &Graph{Root: &Node{ID: "root", Children: []*Node{
+		{ID: "bad", Type: "string", Script: "false"},
+		{ID: "slow", Type: "string", Script: "sleep 60", Children: []*Node{
+			{ID: "after_slow", Type: "string", Script: "true"},
+		}},
+	}}}
+	boom := errors.New("boom")
+	err := g.Execute(context.Background(), 4, func(ctx context.Context, n *Node) error {
+		n.Start()
+		switch n.ID {
+		case "bad":
+			n.Finish(StatusFailed)
+			return boom
+		case "slow":
+			<-ctx.Done()
+			// like a killed script, reported as failed by the task
+			n.Finish(StatusFailed)
+			return ctx.Err()
+		}
+		return nil
+	})
+	if !errors.Is(err, boom) {
+		t.Fatalf("Execute = %v, want the failure of bad", err)
+	}
+	byID := g.Root.Children
+	if byID[0].Status != StatusFailed || byID[1].Status != StatusSkipped {
+		t.Errorf("bad %q, slow %q; want failed and skipped", byID[0].Status, byID[1].Status)
+	}
+	if got := g.NodeByID("after_slow").Status; got != StatusSkipped {
+		t.Errorf("after_slow %q, want skipped", got)
+	}
+	if g.Status != JobFailed {
+		t.Errorf("job status = %q, want %q", g.Status, JobFailed)
+	}
+}
+
+func TestExecuteFailFastLocalNode(t *testing.T) {
+	g := &Graph{Root: &Node{ID: "root", Children: []*Node{
+		{ID: "size", Type: NodeTypeTransform, Transform: &Transform{Input: "disks", Unit: "GiB"},
+			Children: []*Node{{ID: "disks", Type: "string", Script: "lsblk -J"}}},
+		{ID: "later", Type: "string", Script: "true", DependsOn: []string{"size"}},
+	}}}
+	err := g.Execute(context.Background(), 4, func(_ context.Context, n *Node) error {
+		if n.ID == "disks" {
+			n.Result = "not a size"
+		}
+		n.Finish(StatusOK)
+		return nil
+	})
+	if err == nil || errors.Is(err, ErrTooManyFailures) || !strings.Contains(err.Error(), `node "size"`) {
+		t.Fatalf("Execute = %v, want the failure of size", err)
+	}
+	if got := g.NodeByID("size").Status; got != StatusFailed {
+		t.Errorf("size %q, want failed", got)
+	}
+	if got := g.NodeByID("later").Status; got != StatusSkipped {
+		t.Errorf("later %q, want skipped", got)
+	}
+}
+
+func TestRenderScriptRejectsNonDependency(t *testing.T) {
+	g := testGraph()
+	cpu := g.NodeByID("cpu")
+	cpu.Script = `echo {{ result "distro" }}`
+	if _, err := g.RenderScript(cpu); err == nil {
+		t.Error("expected error referencing a node that is not a dependency")
+	}
+}
+
+func TestData(t *testing.T) {
+	g := testGraph()
+	g.NodeByID("distro").Result = "debian"
+	g.NodeByID("packages").Status = StatusSkipped
+	g.NodeByID("cpu").Result = map[string]any{"Architecture": "x86_64", "CPU(s)": 8.0}
+
+	want := map[string]any{
+		"distro": "debian",
+		"system": map[string]any{"cpu": map[string]any{"Architecture": "x86_64", "CPU(s)": 8.0}},
+	}
+	if got := g.Data(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Data() = %#v, want %#v", got, want)
+	}
+}
+
+func TestResultText(t *testing.T) {
+	tests := []struct {
+		v    any
+		want string
+	}{
+		{"debian", "debian"},
+		{[]any{"a", 2.0}, "a\n2"},
+		{map[string]any{"k": true}, `{"k":true}`},
+		{nil, ""},
+	}
+	for _, tt := range tests {
+		if got := ResultText(tt.v); got != tt.want {
+			t.Errorf("ResultText(%#v) = %q, want %q", tt.v, got, tt.want)
+		}
+	}
+}
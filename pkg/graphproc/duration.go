@@ -0,0 +1,37 @@
+package graphproc
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Duration is a time.Duration written in JSON as a string such as "30s"
+// or "2m"; plain numbers are read as seconds.
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	switch t := v.(type) {
+	case float64:
+		*d = Duration(t * float64(time.Second))
+	case string:
+		parsed, err := time.ParseDuration(t)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q: %w", t, err)
+		}
+		*d = Duration(parsed)
+	case nil:
+		*d = 0
+	default:
+		return fmt.Errorf("invalid duration %s", data)
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package graphproc
+
+import (
+	"fmt"
+	"path"
+	"time"
+)
+
+// NodeTypeFetch nodes download a file from the host instead of running a
+// script.
+const NodeTypeFetch = "fetch"
+
+// Checksum algorithms of fetch nodes.
+const (
+	ChecksumSHA256 = "sha256"
+	ChecksumSHA1   = "sha1"
+	ChecksumMD5    = "md5"
+)
+
+// Fetch names the file a fetch node downloads. The content becomes the
+// node result, as text or base64 for binary files, and goes through
+// post_process like script output.
+type Fetch struct {
+	Path     string `json:"path"`                // absolute path on the host
+	Checksum string `json:"checksum,omitempty"`  // sha256 (default), sha1 or md5
+	HashOnly bool   `json:"hash_only,omitempty"` // record size and checksum but not the content
+}
+
+// FileInfo describes the file downloaded by a fetch node.
+type FileInfo struct {
+	Path     string     `json:"path"`
+	Size     int64      `json:"size"`               // bytes read, the whole file even if truncated
+	Mode     string     `json:"mode,omitempty"`     // e.g. -rw-r--r--
+	ModTime  *time.Time `json:"mod_time,omitempty"`
+	Checksum string     `json:"checksum,omitempty"` // of the whole file, e.g. sha256:9f86d0...
+	Encoding string     `json:"encoding,omitempty"` // base64 when the content is not UTF-8 text
+}
+
+// ChecksumAlgorithm returns the checksum algorithm of the fetch spec.
+func (f *Fetch) ChecksumAlgorithm() string {
+	if f.Checksum == "" {
+		return ChecksumSHA256
+	}
+	return f.Checksum
+}
+
+// validateFetch checks the fetch spec of a fetch node.
+func (n *Node) validateFetch() error {
+	if n.Type != NodeTypeFetch {
+		return nil
+	}
+	f := n.Fetch
+	if f == nil || f.Path == "" {
+		return fmt.Errorf("fetch node %q needs fetch.path", n.ID)
+	}
+	if !path.IsAbs(f.Path) {
+		return fmt.Errorf("fetch node %q: path %q is not absolute", n.ID, f.Path)
+	}
+	switch f.ChecksumAlgorithm() {
+	case ChecksumSHA256, ChecksumSHA1, ChecksumMD5:
+	default:
+		return fmt.Errorf("fetch node %q: unknown checksum %q", n.ID, f.Checksum)
+	}
+	return nil
+}
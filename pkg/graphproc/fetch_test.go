@@ -0,0 +1,56 @@
+package graphproc
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestValidateFetchNode(t *testing.T) {
+	tests := []struct {
+		name  string
+		fetch *Fetch
+		err   string
+	}{
+		{"ok", &Fetch{Path: "/etc/os-release"}, ""},
+		{"md5", &Fetch{Path: "/etc/hosts", Checksum: ChecksumMD5, HashOnly: true}, ""},
+		{"no spec", nil, "needs fetch.path"},
+		{"relative", &Fetch{Path: "etc/hosts"}, "not absolute"},
+		{"checksum", &Fetch{Path: "/etc/hosts", Checksum: "crc32"}, "unknown checksum"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateNode(&Node{ID: "f", Type: NodeTypeFetch, Fetch: tt.fetch})
+			if tt.err == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("err = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestFetchNodeJSON(t *testing.T) {
+	n := &Node{ID: "hosts", Type: NodeTypeFetch, Fetch: &Fetch{Path: "/etc/hosts"},
+		Result: "127.0.0.1 localhost\n", File: &FileInfo{Path: "/etc/hosts", Size: 20, Checksum: "sha256:ab"}}
+	data, err := json.Marshal(n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]any
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	file, ok := got["file"].(map[string]any)
+	if !ok || file["checksum"] != "sha256:ab" || file["size"] != float64(20) {
+		t.Errorf("file = %v", got["file"])
+	}
+	fetch, ok := got["fetch"].(map[string]any)
+	if !ok || fetch["path"] != "/etc/hosts" {
+		t.Errorf("fetch = %v", got["fetch"])
+	}
+}
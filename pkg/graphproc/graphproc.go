@@ -3,8 +3,11 @@ package graphproc
 import (
 	"encoding/json"
 	"fmt"
+	"net"
 	"os"
+	"strconv"
 	"sync"
+	"time"
 	"github.com/google/uuid"
 )
 
@@ -13,11 +16,38 @@ type Node struct {
 	Type        string   `json:"type,omitempty"`        
 	Script      string   `json:"script,omitempty"`      
 	PostProcess string   `json:"post_process,omitempty"` 
+	Interpreter string   `json:"interpreter,omitempty"`	// upload the script and run it with sh, bash, python3 or powershell
+	Args        []string `json:"args,omitempty"`		// arguments of an uploaded script
+	Env         map[string]string `json:"env,omitempty"`	// environment of an uploaded script
 	Children    []*Node  `json:"children,omitempty"`    
-	Result      []string `json:"result,omitempty"`      
+	DependsOn   []string `json:"depends_on,omitempty"`	// IDs of nodes that must finish first
+	Timeout     Duration `json:"timeout,omitempty"`		// kill the script after this long, e.g. "30s"
+	MaxOutputBytes int64 `json:"max_output_bytes,omitempty"`	// keep at most this much of stdout and of stderr
+	MaxLines    int      `json:"max_lines,omitempty"`	// keep at most this many lines of stdout and of stderr
+	Condition   *Condition `json:"condition,omitempty"`	// for nodes of type "condition"
+	Transform   *Transform `json:"transform,omitempty"`	// for nodes of type "transform"
+	Aggregate   *Aggregate `json:"aggregate,omitempty"`	// for nodes of type "aggregate"
+	Fetch       *Fetch     `json:"fetch,omitempty"`		// for nodes of type "fetch"
+	Become      *Become    `json:"become,omitempty"`		// run the script as another user
+	Result      any      `json:"result,omitempty"`	// typed value, see package processor
 	Stderr 		[]string `json:"error,omitempty"`
+	Status      NodeStatus `json:"status,omitempty"`
+	ConditionMet *bool     `json:"condition_met,omitempty"`
+	ExitCode    *int       `json:"exit_code,omitempty"`	// nil if the script did not exit
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+	DurationMs  int64      `json:"duration_ms,omitempty"`
+	Attempts    int        `json:"attempts,omitempty"`		// includes retries after transport errors
+	RunError    string     `json:"run_error,omitempty"`	// why the script failed or timed out
+	ProcessError string    `json:"process_error,omitempty"`	// post_process failure, result holds raw output
+	Truncated   bool       `json:"truncated,omitempty"`	// output hit a limit and the script was killed
+	File        *FileInfo  `json:"file,omitempty"`		// downloaded file of a fetch node
 }
 
+// HostTypeLocal makes the collector run the scripts on its own machine
+// instead of connecting over SSH.
+const HostTypeLocal = "local"
+
 type HostConfig struct {
 	CustomerID int `json:"customerId"`			// Customer ID PostgreSQL
 	HostID      int    `json:"hostId"`			// Host ID PostgreSQL
@@ -29,6 +59,33 @@ type HostConfig struct {
 	HostPass 	string `json:"hostpass"`
 	HostKey  	string `json:"hostkey"`
 	HostType 	string `json:"hosttype"`
+	CredentialSource string `json:"credentialSource,omitempty"`	// file | env | agent | vault
+	CredentialRef    string `json:"credentialRef,omitempty"`		// key file, env name, vault entry...
+	Container        string `json:"container,omitempty"`			// run scripts inside this container on the host
+	ContainerRuntime string `json:"containerRuntime,omitempty"`	// docker (default) | podman | crictl | nsenter
+	JumpHosts        []JumpHost `json:"jumpHosts,omitempty"`		// bastions to connect through, in order
+	Groups           []string          `json:"groups,omitempty"`	// device groups, for batch requests
+	Labels           map[string]string `json:"labels,omitempty"`	// labels, for batch requests
+}
+
+// JumpHost is a bastion on the way to a host, like an entry of OpenSSH
+// ProxyJump. User and credentials default to those of the host.
+type JumpHost struct {
+	Host             string `json:"host"`
+	Port             int    `json:"port,omitempty"`			// 22 if unset
+	User             string `json:"user,omitempty"`
+	CredentialSource string `json:"credentialSource,omitempty"`
+	CredentialRef    string `json:"credentialRef,omitempty"`
+	HostKeyPolicy    string `json:"hostKeyPolicy,omitempty"`	// strict | tofu | insecure, collector default if unset
+}
+
+// Addr returns the host:port of the jump host.
+func (j JumpHost) Addr() string {
+	port := j.Port
+	if port == 0 {
+		port = 22
+	}
+	return net.JoinHostPort(j.Host, strconv.Itoa(port))
 }
 
 type Config struct {
@@ -40,14 +97,40 @@ type Config struct {
 	CustomerID string    `json:"customer_id"`
 	HostID     string    `json:"host_id"`
 	Structure  *Node     `json:"structure"`
+	FailurePolicy *FailurePolicy `json:"failure_policy,omitempty"`
 }
 
 type alias struct {
 	ID          string   `json:"id"`
 	Type        string   `json:"type,omitempty"`
+	Script      string   `json:"script,omitempty"`
+	PostProcess string   `json:"post_process,omitempty"`
+	Interpreter string   `json:"interpreter,omitempty"`
+	Args        []string `json:"args,omitempty"`
+	Env         map[string]string `json:"env,omitempty"`
 	Children    []*Node  `json:"children,omitempty"`
-	Result      []string `json:"result,omitempty"`
+	DependsOn   []string `json:"depends_on,omitempty"`
+	Timeout     Duration `json:"timeout,omitempty"`
+	MaxOutputBytes int64 `json:"max_output_bytes,omitempty"`
+	MaxLines    int      `json:"max_lines,omitempty"`
+	Condition   *Condition `json:"condition,omitempty"`
+	Transform   *Transform `json:"transform,omitempty"`
+	Aggregate   *Aggregate `json:"aggregate,omitempty"`
+	Fetch       *Fetch     `json:"fetch,omitempty"`
+	Become      *Become    `json:"become,omitempty"`
+	Result      any      `json:"result,omitempty"`
 	Error		[]string `json:"error,omitempty"`
+	Status      NodeStatus `json:"status,omitempty"`
+	ConditionMet *bool     `json:"condition_met,omitempty"`
+	ExitCode    *int       `json:"exit_code,omitempty"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+	DurationMs  int64      `json:"duration_ms,omitempty"`
+	Attempts    int        `json:"attempts,omitempty"`
+	RunError    string     `json:"run_error,omitempty"`
+	ProcessError string    `json:"process_error,omitempty"`
+	Truncated   bool       `json:"truncated,omitempty"`
+	File        *FileInfo  `json:"file,omitempty"`
 }
 
 type Graph struct {
@@ -55,6 +138,9 @@ type Graph struct {
 	HostCfg *HostConfig	`json:"hostconfig,omitempty"`
 	UUID     uuid.UUID	`json:"uuid,omitempty"`
 	Root    *Node		`json:"rootnode,omitempty"`
+	Error    string		`json:"error,omitempty"`	// job level failure, e.g. host key mismatch
+	Status   JobStatus	`json:"status,omitempty"`	// complete, partial or failed
+	BatchID  string		`json:"batchid,omitempty"`	// batch request the graph was collected for
 }
 
 func (g *Graph) MarshalJSON() ([]byte, error) {
@@ -73,14 +159,57 @@ func (n *Node) MarshalJSON() ([]byte, error) {
 	alias := &alias{
 		ID:          n.ID,
 		Type:        n.Type,
+		Script:      n.Script,
+		PostProcess: n.PostProcess,
+		Interpreter: n.Interpreter,
+		Args:        n.Args,
+		Env:         n.Env,
 		Children:    n.Children,
+		DependsOn:   n.DependsOn,
+		Timeout:     n.Timeout,
+		MaxOutputBytes: n.MaxOutputBytes,
+		MaxLines:    n.MaxLines,
+		Condition:   n.Condition,
+		Transform:   n.Transform,
+		Aggregate:   n.Aggregate,
+		Fetch:       n.Fetch,
+		Become:      n.Become,
 		Result:      n.Result,
 		Error:		 n.Stderr,
+		Status:      n.Status,
+		ConditionMet: n.ConditionMet,
+		ExitCode:    n.ExitCode,
+		StartedAt:   n.StartedAt,
+		FinishedAt:  n.FinishedAt,
+		DurationMs:  n.DurationMs,
+		Attempts:    n.Attempts,
+		RunError:    n.RunError,
+		ProcessError: n.ProcessError,
+		Truncated:   n.Truncated,
+		File:        n.File,
 	}
 
 	return json.Marshal(alias)
 }
 
+// Start records the start time of a node run.
+func (n *Node) Start() {
+	now := time.Now().UTC()
+	n.StartedAt = &now
+	n.FinishedAt = nil
+	n.DurationMs = 0
+}
+
+// Finish records the end time and final status of a node run.
+func (n *Node) Finish(status NodeStatus) {
+	now := time.Now().UTC()
+	n.FinishedAt = &now
+	if n.StartedAt != nil {
+		n.DurationMs = now.Sub(*n.StartedAt).Milliseconds()
+	}
+	n.Status = status
+}
+
 func NewGraphFromJSON(filePath string) (*Graph, error) {
 	data, err := os.ReadFile(filePath)
 	if err != nil {
@@ -92,10 +221,37 @@ func NewGraphFromJSON(filePath string) (*Graph, error) {
 		return nil, fmt.Errorf("failed to unmarshal JSON: %v", err)
 	}
 
+	return NewGraph(&config), nil
+}
+
+// NewGraph creates a graph rooted at the structure of the script config.
+func NewGraph(config *Config) *Graph {
 	return &Graph{
-		Config: &config,
+		Config: config,
 		Root:   config.Structure,
-	}, nil
+	}
+}
+
+// RemoteAddr returns the host:port to connect to. The host configuration
+// wins over the remote_host of the script document.
+func (g *Graph) RemoteAddr() string {
+	if h := g.HostCfg; h != nil {
+		host := h.HostIP
+		if host == "" {
+			host = h.HostName
+		}
+		if host != "" {
+			port := h.HostPort
+			if port == 0 {
+				port = 22
+			}
+			return net.JoinHostPort(host, strconv.Itoa(port))
+		}
+	}
+	if g.Config != nil {
+		return g.Config.RemoteHost
+	}
+	return ""
 }
 
 func (g *Graph) NodeGenerator() <-chan *Node {
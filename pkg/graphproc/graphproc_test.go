@@ -0,0 +1,92 @@
+package graphproc
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// TestGraphJSONRoundTrip posts a collected graph the way the collector
+// does and validates it the way the dataservice does.
+func TestGraphJSONRoundTrip(t *testing.T) {
+	equals := "Linux"
+	root := &Node{
+		ID: "root",
+		Children: []*Node{
+			{ID: "hosts", Type: NodeTypeFetch, Fetch: &Fetch{Path: "/etc/hosts", HashOnly: true}},
+			{ID: "is_linux", Type: NodeTypeCondition, Script: "uname -s",
+				Condition: &Condition{Equals: &equals},
+				Children:  []*Node{{ID: "kernel", Type: "string", Script: "uname -r", Timeout: Duration(30e9)}},
+			},
+			{ID: "disks", Type: "string", Script: "lsblk -J -b", Interpreter: "sh", Args: []string{"-x"}},
+			{ID: "disk_sizes", Type: NodeTypeTransform, DependsOn: []string{"disks"},
+				Transform: &Transform{Input: "disks", Path: ".blockdevices[].size", Unit: "GiB"}},
+			{ID: "summary", Type: NodeTypeAggregate, Aggregate: &Aggregate{Inputs: []string{"kernel", "disk_sizes"}}},
+		},
+	}
+	g := &Graph{Config: &Config{Version: "1", Structure: root}, Root: root, UUID: uuid.New()}
+
+	outputs := map[string]any{
+		"is_linux": "Linux",
+		"kernel":   "6.1.0",
+		"disks": map[string]any{"blockdevices": []any{
+			map[string]any{"name": "sda", "size": 21474836480.0},
+		}},
+	}
+	err := g.Execute(context.Background(), 4, func(_ context.Context, n *Node) error {
+		if n.Type == NodeTypeFetch {
+			n.File = &FileInfo{Path: n.Fetch.Path, Size: 20, Checksum: "sha256:ab"}
+			return nil
+		}
+		n.Result = outputs[n.ID]
+		if n.Condition != nil {
+			met, err := n.Condition.Evaluate(resultLines(n.Result), 0)
+			n.ConditionMet = &met
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+
+	data, err := json.Marshal(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got Graph
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if err := ValidateGraph(&got); err != nil {
+		t.Fatalf("ValidateGraph: %v", err)
+	}
+
+	byID := map[string]*Node{}
+	var walk func(n *Node)
+	walk = func(n *Node) {
+		byID[n.ID] = n
+		for _, c := range n.Children {
+			walk(c)
+		}
+	}
+	walk(got.Root)
+	if n := byID["hosts"]; n.Fetch == nil || n.Fetch.Path != "/etc/hosts" || n.File == nil {
+		t.Errorf("hosts fetch = %+v, file %+v", n.Fetch, n.File)
+	}
+	if n := byID["disk_sizes"]; n.Transform == nil || n.Transform.Input != "disks" || !reflect.DeepEqual(n.Result, []any{20.0}) {
+		t.Errorf("disk_sizes = %+v, result %v", n.Transform, n.Result)
+	}
+	if n := byID["is_linux"]; n.Condition == nil || n.ConditionMet == nil || !*n.ConditionMet {
+		t.Errorf("is_linux condition = %+v, met %v", n.Condition, n.ConditionMet)
+	}
+	if n := byID["summary"]; n.Aggregate == nil || len(n.Aggregate.Inputs) != 2 {
+		t.Errorf("summary aggregate = %+v", n.Aggregate)
+	}
+	if n := byID["kernel"]; n.Timeout != Duration(30e9) || n.Result != "6.1.0" {
+		t.Errorf("kernel timeout = %v, result %v", n.Timeout, n.Result)
+	}
+}
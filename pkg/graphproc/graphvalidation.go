@@ -26,6 +26,7 @@ func validateNodeType(fl validator.FieldLevel) bool {
 		"condition":  true,
 		"aggregate":  true,
 		"transform":  true,
+		"fetch":      true,
 		"":           true, // Allow empty type
 	}
 	return validTypes[fl.Field().String()]
@@ -66,7 +67,10 @@ func isCyclic(node *Node, visited map[string]bool) bool {
 }
 
 func ValidateConfig(cfg *Config) error {
-	return validate.Struct(cfg)
+	if err := validate.Struct(cfg); err != nil {
+		return err
+	}
+	return cfg.FailurePolicy.Validate()
 }
 
 func ValidateGraph(graph *Graph) error {
@@ -82,6 +86,10 @@ func ValidateGraph(graph *Graph) error {
 		return fmt.Errorf("graph contains cycles")
 	}
 
+	if _, err := graph.Levels(); err != nil {
+		return fmt.Errorf("dependency validation failed: %w", err)
+	}
+
 	if graph.UUID != uuid.Nil {
 		if _, err := uuid.Parse(graph.UUID.String()); err != nil {
 			return fmt.Errorf("invalid UUID: %w", err)
@@ -122,12 +130,32 @@ func ValidateNode(node *Node) error {
 		return fmt.Errorf("script is required for nodes of type 'exec'")
 	}
 
-	if len(node.Result) > 0 {
-		for i, result := range node.Result {
-			if strings.TrimSpace(result) == "" {
-				return fmt.Errorf("result at index %d cannot be empty", i)
-			}
-		}
+	if err := node.Condition.Validate(); err != nil {
+		return err
+	}
+
+	if err := node.validateLocal(); err != nil {
+		return err
+	}
+
+	if err := node.validateFetch(); err != nil {
+		return err
+	}
+
+	if err := node.validateBecome(); err != nil {
+		return err
+	}
+
+	if err := node.validateInterpreter(); err != nil {
+		return err
+	}
+
+	if node.Timeout < 0 || node.MaxOutputBytes < 0 || node.MaxLines < 0 {
+		return fmt.Errorf("timeout, max_output_bytes and max_lines cannot be negative")
+	}
+
+	if err := validateValue(node.Result); err != nil {
+		return fmt.Errorf("invalid result: %w", err)
 	}
 
 	if len(node.Stderr) > 0 {
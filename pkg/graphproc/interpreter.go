@@ -0,0 +1,39 @@
+package graphproc
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// Interpreters of uploaded scripts, see Node.Interpreter.
+const (
+	InterpreterSh         = "sh"
+	InterpreterBash       = "bash"
+	InterpreterPython3    = "python3"
+	InterpreterPowerShell = "powershell"
+)
+
+var envName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// validateInterpreter checks the interpreter, args and env of a node.
+func (n *Node) validateInterpreter() error {
+	switch n.Interpreter {
+	case "":
+		if len(n.Args) > 0 || len(n.Env) > 0 {
+			return fmt.Errorf("node %q: args and env need an interpreter", n.ID)
+		}
+		return nil
+	case InterpreterSh, InterpreterBash, InterpreterPython3, InterpreterPowerShell:
+	default:
+		return fmt.Errorf("node %q: unknown interpreter %q", n.ID, n.Interpreter)
+	}
+	if n.IsLocal() || n.Type == NodeTypeFetch {
+		return fmt.Errorf("node %q: %s nodes run no script", n.ID, n.Type)
+	}
+	for name := range n.Env {
+		if !envName.MatchString(name) {
+			return fmt.Errorf("node %q: invalid env name %q", n.ID, name)
+		}
+	}
+	return nil
+}
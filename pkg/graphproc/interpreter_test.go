@@ -0,0 +1,35 @@
+package graphproc
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateInterpreter(t *testing.T) {
+	tests := []struct {
+		name string
+		node *Node
+		err  string
+	}{
+		{"bash", &Node{ID: "n", Script: "echo $1", Interpreter: InterpreterBash, Args: []string{"a"}, Env: map[string]string{"LANG": "C"}}, ""},
+		{"plain", &Node{ID: "n", Script: "uname"}, ""},
+		{"unknown", &Node{ID: "n", Script: "x", Interpreter: "perl"}, "unknown interpreter"},
+		{"args without interpreter", &Node{ID: "n", Script: "x", Args: []string{"a"}}, "need an interpreter"},
+		{"env name", &Node{ID: "n", Script: "x", Interpreter: InterpreterSh, Env: map[string]string{"A-B": "1"}}, "invalid env name"},
+		{"fetch", &Node{ID: "n", Type: NodeTypeFetch, Fetch: &Fetch{Path: "/etc/hosts"}, Interpreter: InterpreterSh}, "run no script"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateNode(tt.node)
+			if tt.err == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("err = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
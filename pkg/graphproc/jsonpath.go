@@ -0,0 +1,121 @@
+package graphproc
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// pathStep is one segment of a path expression: a key, an index or an
+// iteration over every element ("[]").
+type pathStep struct {
+	key     string
+	index   int
+	isIndex bool
+	iterate bool
+}
+
+// parsePath parses a jq-like path such as `.cpu."Model name"`, `.disks[0]`
+// or `.disks[].mount`. An empty path or "." selects the whole value.
+func parsePath(path string) ([]pathStep, error) {
+	var steps []pathStep
+	s := strings.TrimSpace(path)
+	for len(s) > 0 {
+		switch s[0] {
+		case '.':
+			s = s[1:]
+			if s == "" {
+				return steps, nil
+			}
+			if s[0] == '[' {
+				continue
+			}
+			if s[0] == '"' {
+				end := strings.IndexByte(s[1:], '"')
+				if end < 0 {
+					return nil, fmt.Errorf("unterminated quoted key in path %q", path)
+				}
+				steps = append(steps, pathStep{key: s[1 : end+1]})
+				s = s[end+2:]
+				continue
+			}
+			end := strings.IndexAny(s, ".[")
+			if end < 0 {
+				end = len(s)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("empty key in path %q", path)
+			}
+			steps = append(steps, pathStep{key: s[:end]})
+			s = s[end:]
+		case '[':
+			end := strings.IndexByte(s, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated index in path %q", path)
+			}
+			inner := strings.TrimSpace(s[1:end])
+			s = s[end+1:]
+			if inner == "" {
+				steps = append(steps, pathStep{iterate: true})
+				continue
+			}
+			if unq, err := strconv.Unquote(inner); err == nil {
+				steps = append(steps, pathStep{key: unq})
+				continue
+			}
+			i, err := strconv.Atoi(inner)
+			if err != nil {
+				return nil, fmt.Errorf("invalid index %q in path %q", inner, path)
+			}
+			steps = append(steps, pathStep{index: i, isIndex: true})
+		default:
+			return nil, fmt.Errorf("path %q must start with '.'", path)
+		}
+	}
+	return steps, nil
+}
+
+// selectPath applies a parsed path to v. Missing keys and indexes select nil.
+func selectPath(v any, steps []pathStep) any {
+	for i, step := range steps {
+		switch {
+		case step.iterate:
+			var items []any
+			switch t := v.(type) {
+			case []any:
+				items = t
+			case map[string]any:
+				for _, k := range sortedKeys(t) {
+					items = append(items, t[k])
+				}
+			default:
+				return nil
+			}
+			out := make([]any, 0, len(items))
+			for _, item := range items {
+				out = append(out, selectPath(item, steps[i+1:]))
+			}
+			return out
+		case step.isIndex:
+			list, ok := v.([]any)
+			if !ok {
+				return nil
+			}
+			idx := step.index
+			if idx < 0 {
+				idx += len(list)
+			}
+			if idx < 0 || idx >= len(list) {
+				return nil
+			}
+			v = list[idx]
+		default:
+			m, ok := v.(map[string]any)
+			if !ok {
+				return nil
+			}
+			v = m[step.key]
+		}
+	}
+	return v
+}
@@ -0,0 +1,318 @@
+package graphproc
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	NodeTypeAggregate = "aggregate"
+	NodeTypeTransform = "transform"
+)
+
+// Aggregate modes.
+const (
+	AggregateObject = "object" // {"<input id>": value, ...}
+	AggregateList   = "list"   // [value, ...] in input order
+	AggregateMerge  = "merge"  // keys of all object inputs merged, later inputs win
+)
+
+// Transform derives a value from the result of another node. The steps run
+// in the order path, regex, unit.
+type Transform struct {
+	Input string `json:"input,omitempty"` // source node ID, defaults to the only child
+	Path  string `json:"path,omitempty"`  // jq-like selection on JSON output, e.g. .blockdevices[].size
+	Regex string `json:"regex,omitempty"` // keeps the first capture group, or the whole match
+	Unit  string `json:"unit,omitempty"`  // converts sizes such as "16G" or "512 kB" to this unit
+	From  string `json:"from,omitempty"`  // unit of values without a suffix, defaults to bytes
+}
+
+// Aggregate merges the results of several nodes into one value.
+type Aggregate struct {
+	Inputs []string `json:"inputs,omitempty"` // source node IDs, defaults to the children
+	Mode   string   `json:"mode,omitempty"`   // object (default), list or merge
+}
+
+// IsLocal reports whether the node is evaluated on the collector instead
+// of running a script on the host.
+func (n *Node) IsLocal() bool {
+	return n.Type == NodeTypeAggregate || n.Type == NodeTypeTransform
+}
+
+// inputs returns the IDs of the nodes a local node reads from.
+func (n *Node) inputs() []string {
+	switch n.Type {
+	case NodeTypeTransform:
+		if n.Transform != nil && n.Transform.Input != "" {
+			return []string{n.Transform.Input}
+		}
+		if len(n.Children) == 1 {
+			return []string{n.Children[0].ID}
+		}
+	case NodeTypeAggregate:
+		if n.Aggregate != nil && len(n.Aggregate.Inputs) > 0 {
+			return n.Aggregate.Inputs
+		}
+		ids := make([]string, 0, len(n.Children))
+		for _, c := range n.Children {
+			ids = append(ids, c.ID)
+		}
+		return ids
+	}
+	return nil
+}
+
+// validateLocal checks the transform or aggregate spec of a local node.
+func (n *Node) validateLocal() error {
+	switch n.Type {
+	case NodeTypeTransform:
+		if len(n.inputs()) == 0 {
+			return fmt.Errorf("transform node %q needs transform.input or exactly one child", n.ID)
+		}
+		t := n.Transform
+		if t == nil {
+			return nil
+		}
+		if _, err := parsePath(t.Path); err != nil {
+			return err
+		}
+		if t.Regex != "" {
+			if _, err := regexp.Compile(t.Regex); err != nil {
+				return fmt.Errorf("invalid transform regex: %w", err)
+			}
+		}
+		for _, u := range []string{t.Unit, t.From} {
+			if _, ok := unitFactor(u); u != "" && !ok {
+				return fmt.Errorf("unknown unit %q", u)
+			}
+		}
+	case NodeTypeAggregate:
+		if n.Aggregate == nil {
+			return nil
+		}
+		switch n.Aggregate.Mode {
+		case "", AggregateObject, AggregateList, AggregateMerge:
+		default:
+			return fmt.Errorf("unknown aggregate mode %q", n.Aggregate.Mode)
+		}
+	}
+	return nil
+}
+
+// evaluateLocal computes the result of a transform or aggregate node from
+// the results of its inputs.
+func (idx *graphIndex) evaluateLocal(node *Node) error {
+	var (
+		v   any
+		err error
+	)
+	switch node.Type {
+	case NodeTypeTransform:
+		v, err = idx.transform(node)
+	case NodeTypeAggregate:
+		v, err = idx.aggregate(node)
+	}
+	if err != nil {
+		return fmt.Errorf("node %q: %w", node.ID, err)
+	}
+	node.Result = v
+	return nil
+}
+
+func (idx *graphIndex) transform(node *Node) (any, error) {
+	ids := node.inputs()
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("no input")
+	}
+	src := idx.byID[ids[0]]
+	if src == nil {
+		return nil, fmt.Errorf("unknown input %q", ids[0])
+	}
+	v := src.Result
+	t := node.Transform
+	if t == nil {
+		return v, nil
+	}
+	if t.Path != "" {
+		steps, err := parsePath(t.Path)
+		if err != nil {
+			return nil, err
+		}
+		v = selectPath(v, steps)
+	}
+	if t.Regex != "" {
+		re, err := regexp.Compile(t.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid transform regex: %w", err)
+		}
+		v = mapValues(v, func(s string) (any, bool) {
+			m := re.FindStringSubmatch(s)
+			switch {
+			case m == nil:
+				return nil, false
+			case len(m) > 1:
+				return m[1], true
+			default:
+				return m[0], true
+			}
+		})
+	}
+	if t.Unit != "" {
+		var convErr error
+		v = mapValues(v, func(s string) (any, bool) {
+			f, err := convertUnit(s, t.From, t.Unit)
+			if err != nil {
+				convErr = err
+				return nil, false
+			}
+			return f, true
+		})
+		if convErr != nil {
+			return nil, convErr
+		}
+	}
+	return v, nil
+}
+
+func (idx *graphIndex) aggregate(node *Node) (any, error) {
+	mode := AggregateObject
+	if node.Aggregate != nil && node.Aggregate.Mode != "" {
+		mode = node.Aggregate.Mode
+	}
+	ids := node.inputs()
+	switch mode {
+	case AggregateList:
+		out := make([]any, 0, len(ids))
+		for _, id := range ids {
+			if src := idx.byID[id]; src != nil && !excluded(src) {
+				out = append(out, src.Result)
+			}
+		}
+		return out, nil
+	case AggregateMerge:
+		out := make(map[string]any)
+		for _, id := range ids {
+			src := idx.byID[id]
+			if src == nil || excluded(src) {
+				continue
+			}
+			m, ok := src.Result.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("input %q is not an object", id)
+			}
+			for k, val := range m {
+				out[k] = val
+			}
+		}
+		return out, nil
+	default:
+		out := make(map[string]any, len(ids))
+		for _, id := range ids {
+			if src := idx.byID[id]; src != nil && !excluded(src) {
+				out[id] = src.Result
+			}
+		}
+		return out, nil
+	}
+}
+
+// excluded reports whether an aggregate input has no usable result.
+func excluded(n *Node) bool {
+	return n.Status == StatusSkipped || n.Status.failed()
+}
+
+// mapValues applies fn to every scalar in v; lists are mapped element by
+// element and elements fn rejects are dropped.
+func mapValues(v any, fn func(string) (any, bool)) any {
+	switch t := v.(type) {
+	case []any:
+		out := make([]any, 0, len(t))
+		for _, item := range t {
+			if r := mapValues(item, fn); r != nil {
+				out = append(out, r)
+			}
+		}
+		return out
+	case string:
+		if r, ok := fn(t); ok {
+			return r
+		}
+	case float64:
+		if r, ok := fn(strconv.FormatFloat(t, 'f', -1, 64)); ok {
+			return r
+		}
+	case bool:
+		if r, ok := fn(strconv.FormatBool(t)); ok {
+			return r
+		}
+	}
+	return nil
+}
+
+// unitFactor returns the size of a unit in bytes. Single letters and "kB"
+// follow the Linux tools (df -h, free, /proc/meminfo) and are binary; KB,
+// MB, GB and TB are decimal.
+func unitFactor(unit string) (float64, bool) {
+	const k = 1024
+	switch strings.TrimSpace(unit) {
+	case "", "B", "b", "bytes":
+		return 1, true
+	case "K", "k", "Ki", "KiB", "kB":
+		return k, true
+	case "M", "Mi", "MiB":
+		return k * k, true
+	case "G", "Gi", "GiB":
+		return k * k * k, true
+	case "T", "Ti", "TiB":
+		return k * k * k * k, true
+	case "KB":
+		return 1e3, true
+	case "MB":
+		return 1e6, true
+	case "GB":
+		return 1e9, true
+	case "TB":
+		return 1e12, true
+	}
+	return 0, false
+}
+
+// convertUnit parses a size such as "15Gi", "512 kB" or "1.5T" and returns
+// it in unit, rounded to two decimals. Numbers without a suffix are in from.
+func convertUnit(s, from, unit string) (float64, error) {
+	s = strings.TrimSpace(s)
+	end := 0
+	for end < len(s) && (s[end] >= '0' && s[end] <= '9' || s[end] == '.' || s[end] == '-') {
+		end++
+	}
+	num, err := strconv.ParseFloat(s[:end], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	suffix := strings.TrimSpace(s[end:])
+	if suffix == "" {
+		suffix = from
+	}
+	src, ok := unitFactor(suffix)
+	if !ok {
+		return 0, fmt.Errorf("unknown unit in %q", s)
+	}
+	dst, ok := unitFactor(unit)
+	if !ok {
+		return 0, fmt.Errorf("unknown unit %q", unit)
+	}
+	return math.Round(num*src/dst*100) / 100, nil
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,134 @@
+package graphproc
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestExecuteEvaluatesLocalNodes(t *testing.T) {
+	g := &Graph{Root: &Node{
+		ID: "root",
+		Children: []*Node{
+			{ID: "disks", Type: "string", Script: "lsblk -J -b"},
+			{ID: "disk_sizes", Type: NodeTypeTransform,
+				Transform: &Transform{Input: "disks", Path: ".blockdevices[].size", Unit: "GiB"}},
+			{ID: "summary", Type: NodeTypeAggregate, Children: []*Node{
+				{ID: "kernel", Type: "string", Script: "uname -r"},
+				{ID: "mem_mib", Type: NodeTypeTransform,
+					Transform: &Transform{Regex: `MemTotal:\s+(\d+ kB)`, Unit: "MiB"},
+					Children:  []*Node{{ID: "meminfo", Type: "array", Script: "cat /proc/meminfo"}},
+				},
+			}},
+		},
+	}}
+	if err := ValidateGraph(&Graph{Config: &Config{Structure: g.Root}, Root: g.Root}); err != nil {
+		t.Fatalf("ValidateGraph: %v", err)
+	}
+
+	outputs := map[string]any{
+		"disks": map[string]any{"blockdevices": []any{
+			map[string]any{"name": "sda", "size": 21474836480.0},
+			map[string]any{"name": "sdb", "size": 1073741824.0},
+		}},
+		"kernel":  "6.1.0",
+		"meminfo": []any{"MemTotal:       2048000 kB", "MemFree:        1024 kB"},
+	}
+	err := g.Execute(context.Background(), 4, func(_ context.Context, n *Node) error {
+		if n.IsLocal() {
+			t.Errorf("local node %q passed to fn", n.ID)
+		}
+		n.Result = outputs[n.ID]
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+
+	want := map[string]any{
+		"disk_sizes": []any{20.0, 1.0},
+		"mem_mib":    []any{2000.0},
+		"summary":    map[string]any{"kernel": "6.1.0", "mem_mib": []any{2000.0}},
+	}
+	for id, v := range want {
+		if got := g.NodeByID(id).Result; !reflect.DeepEqual(got, v) {
+			t.Errorf("%s = %#v, want %#v", id, got, v)
+		}
+	}
+}
+
+func TestLocalNodesRunAfterInputs(t *testing.T) {
+	g := &Graph{Root: &Node{
+		ID: "root",
+		Children: []*Node{{ID: "agg", Type: NodeTypeAggregate, Children: []*Node{
+			{ID: "a", Script: "echo a"},
+		}}},
+	}}
+	levels, err := g.Levels()
+	if err != nil {
+		t.Fatalf("Levels: %v", err)
+	}
+	got := levelIDs(levels)
+	if len(got) != 3 || got[1][0] != "a" || got[2][0] != "agg" {
+		t.Errorf("levels = %v", got)
+	}
+}
+
+func TestParsePath(t *testing.T) {
+	v := map[string]any{
+		"cpu":   map[string]any{"Model name": "Xeon"},
+		"disks": []any{map[string]any{"mount": "/"}, map[string]any{"mount": "/home"}},
+	}
+	tests := []struct {
+		path string
+		want any
+	}{
+		{`.cpu."Model name"`, "Xeon"},
+		{`.cpu["Model name"]`, "Xeon"},
+		{`.disks[1].mount`, "/home"},
+		{`.disks[-1].mount`, "/home"},
+		{`.missing.key`, nil},
+	}
+	for _, tt := range tests {
+		steps, err := parsePath(tt.path)
+		if err != nil {
+			t.Fatalf("parsePath(%q): %v", tt.path, err)
+		}
+		if got := selectPath(v, steps); got != tt.want {
+			t.Errorf("%s = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+
+	steps, _ := parsePath(".disks[].mount")
+	if got := selectPath(v, steps); !reflect.DeepEqual(got, []any{"/", "/home"}) {
+		t.Errorf(".disks[].mount = %v", got)
+	}
+	if _, err := parsePath("disks"); err == nil {
+		t.Error("expected error for path without leading '.'")
+	}
+}
+
+func TestConvertUnit(t *testing.T) {
+	tests := []struct {
+		in, from, unit string
+		want           float64
+	}{
+		{"16G", "", "MiB", 16384},
+		{"512 kB", "", "KiB", 512},
+		{"1.5T", "", "GiB", 1536},
+		{"2000000000", "", "GB", 2},
+		{"4096", "KiB", "MiB", 4},
+	}
+	for _, tt := range tests {
+		got, err := convertUnit(tt.in, tt.from, tt.unit)
+		if err != nil {
+			t.Fatalf("convertUnit(%q): %v", tt.in, err)
+		}
+		if got != tt.want {
+			t.Errorf("convertUnit(%q, %q) = %v, want %v", tt.in, tt.unit, got, tt.want)
+		}
+	}
+	if _, err := convertUnit("12 parsecs", "", "GiB"); err == nil {
+		t.Error("expected error for unknown unit")
+	}
+}
@@ -0,0 +1,97 @@
+package graphproc
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Failure policy modes.
+const (
+	FailFast        = "fail_fast"    // stop at the first failed node (default)
+	ContinueOnError = "continue"     // run every node whose dependencies succeeded
+	MaxFailures     = "max_failures" // continue until more than max_failures nodes failed
+)
+
+// JobStatus summarizes the outcome of a whole graph.
+type JobStatus string
+
+const (
+	JobComplete JobStatus = "complete" // every node ran successfully or was skipped
+	JobPartial  JobStatus = "partial"  // some nodes failed, the rest of the graph was collected
+	JobFailed   JobStatus = "failed"   // execution stopped or no node succeeded
+)
+
+var ErrTooManyFailures = errors.New("too many failed nodes")
+
+// FailurePolicy decides how Graph.Execute reacts to failed nodes. Nodes
+// that depend on a failed node are always skipped.
+type FailurePolicy struct {
+	Mode        string `json:"mode,omitempty"`
+	MaxFailures int    `json:"max_failures,omitempty"` // for mode max_failures
+}
+
+func (p *FailurePolicy) Validate() error {
+	if p == nil {
+		return nil
+	}
+	switch p.Mode {
+	case "", FailFast, ContinueOnError:
+	case MaxFailures:
+		if p.MaxFailures < 1 {
+			return fmt.Errorf("failure_policy: max_failures must be at least 1")
+		}
+	default:
+		return fmt.Errorf("failure_policy: unknown mode %q", p.Mode)
+	}
+	return nil
+}
+
+// limit returns how many failed nodes are tolerated, -1 for no limit.
+func (p *FailurePolicy) limit() int {
+	if p == nil {
+		return 0
+	}
+	switch p.Mode {
+	case ContinueOnError:
+		return -1
+	case MaxFailures:
+		return p.MaxFailures
+	}
+	return 0
+}
+
+func (g *Graph) failurePolicy() *FailurePolicy {
+	if g.Config == nil {
+		return nil
+	}
+	return g.Config.FailurePolicy
+}
+
+// failed reports whether the node ran and did not succeed.
+func (s NodeStatus) failed() bool {
+	return s == StatusFailed || s == StatusTimeout
+}
+
+// jobStatus derives the job status from the node statuses once execution
+// has ended; execErr is the error Execute returns.
+func (idx *graphIndex) jobStatus(execErr error) JobStatus {
+	if execErr != nil {
+		return JobFailed
+	}
+	ok, failed := 0, 0
+	for _, n := range idx.nodes {
+		switch {
+		case n.Status == StatusOK:
+			ok++
+		case n.Status.failed():
+			failed++
+		}
+	}
+	switch {
+	case failed == 0:
+		return JobComplete
+	case ok == 0:
+		return JobFailed
+	}
+	return JobPartial
+}
@@ -0,0 +1,99 @@
+package graphproc
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func policyGraph(policy *FailurePolicy) *Graph {
+	root := &Node{
+		ID: "root",
+		Children: []*Node{
+			{ID: "bad", Script: "false", Children: []*Node{
+				{ID: "after_bad", Script: "true"},
+			}},
+			{ID: "bad2", Script: "false"},
+			{ID: "good", Script: "true"},
+		},
+	}
+	return &Graph{Config: &Config{Structure: root, FailurePolicy: policy}, Root: root}
+}
+
+func runPolicyGraph(g *Graph) error {
+	return g.Execute(context.Background(), 1, func(_ context.Context, n *Node) error {
+		if n.Script == "false" {
+			return errors.New("exit status 1")
+		}
+		n.Finish(StatusOK)
+		return nil
+	})
+}
+
+func TestFailurePolicies(t *testing.T) {
+	tests := []struct {
+		name      string
+		policy    *FailurePolicy
+		wantErr   error
+		status    JobStatus
+		afterBad  NodeStatus
+		goodState NodeStatus
+	}{
+		{"default fails fast", nil, errors.New(""), JobFailed, StatusSkipped, ""},
+		{"continue", &FailurePolicy{Mode: ContinueOnError}, nil, JobPartial, StatusSkipped, StatusOK},
+		{"threshold not exceeded", &FailurePolicy{Mode: MaxFailures, MaxFailures: 2}, nil, JobPartial, StatusSkipped, StatusOK},
+		{"threshold exceeded", &FailurePolicy{Mode: MaxFailures, MaxFailures: 1}, ErrTooManyFailures, JobFailed, StatusSkipped, StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := policyGraph(tt.policy)
+			err := runPolicyGraph(g)
+			if (err != nil) != (tt.wantErr != nil) {
+				t.Fatalf("Execute error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == ErrTooManyFailures && !errors.Is(err, ErrTooManyFailures) {
+				t.Errorf("error = %v, want ErrTooManyFailures", err)
+			}
+			if g.Status != tt.status {
+				t.Errorf("job status = %q, want %q", g.Status, tt.status)
+			}
+			if tt.wantErr != nil && g.Error == "" {
+				t.Error("graph error not recorded")
+			}
+			if got := g.NodeByID("bad").Status; got != StatusFailed {
+				t.Errorf("bad = %q, want failed", got)
+			}
+			if got := g.NodeByID("after_bad").Status; got != tt.afterBad {
+				t.Errorf("after_bad = %q, want %q", got, tt.afterBad)
+			}
+			if tt.goodState != "" {
+				if got := g.NodeByID("good").Status; got != tt.goodState {
+					t.Errorf("good = %q, want %q", got, tt.goodState)
+				}
+			}
+		})
+	}
+}
+
+func TestFailurePolicyValidate(t *testing.T) {
+	for _, p := range []*FailurePolicy{{Mode: "retry"}, {Mode: MaxFailures}} {
+		if err := p.Validate(); err == nil {
+			t.Errorf("expected error for %+v", p)
+		}
+	}
+}
+
+func TestJobStatusComplete(t *testing.T) {
+	g := policyGraph(nil)
+	for _, n := range g.Nodes() {
+		if n.Script == "false" {
+			n.Script = "true"
+		}
+	}
+	if err := runPolicyGraph(g); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if g.Status != JobComplete {
+		t.Errorf("job status = %q, want complete", g.Status)
+	}
+}
@@ -0,0 +1,104 @@
+package graphproc
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ResultText renders a result value as text: strings as they are, lists
+// one element per line, numbers and booleans in their plain form and
+// objects as JSON.
+func ResultText(v any) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return t
+	case []any:
+		lines := make([]string, len(t))
+		for i, item := range t {
+			lines[i] = ResultText(item)
+		}
+		return strings.Join(lines, "\n")
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(t)
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
+
+// resultLines splits a result into lines; list elements are lines.
+func resultLines(v any) []string {
+	switch t := v.(type) {
+	case nil:
+		return nil
+	case []any:
+		lines := make([]string, len(t))
+		for i, item := range t {
+			lines[i] = ResultText(item)
+		}
+		return lines
+	}
+	return strings.Split(ResultText(v), "\n")
+}
+
+// validateValue checks that v only holds the types of the value model:
+// string, number, bool, nil, lists and objects.
+func validateValue(v any) error {
+	switch t := v.(type) {
+	case nil, string, float64, bool, int, int32, int64:
+		return nil
+	case []any:
+		for i, item := range t {
+			if err := validateValue(item); err != nil {
+				return fmt.Errorf("[%d]: %w", i, err)
+			}
+		}
+		return nil
+	case map[string]any:
+		for k, item := range t {
+			if err := validateValue(item); err != nil {
+				return fmt.Errorf("%s: %w", k, err)
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported type %T", v)
+}
+
+// Data returns the results of the graph as one object keyed by node ID.
+// Nodes with children become nested objects, so lscpu output parsed by
+// node "cpu" under node "system" is found at system.cpu.Architecture.
+// Transform and aggregate nodes contribute their own result; skipped
+// nodes are left out.
+func (g *Graph) Data() map[string]any {
+	if g.Root == nil {
+		return nil
+	}
+	if len(g.Root.Children) == 0 || g.Root.IsLocal() {
+		return map[string]any{g.Root.ID: g.Root.Result}
+	}
+	return childData(g.Root)
+}
+
+func childData(n *Node) map[string]any {
+	out := make(map[string]any, len(n.Children))
+	for _, c := range n.Children {
+		if c.Status == StatusSkipped {
+			continue
+		}
+		if len(c.Children) == 0 || c.IsLocal() {
+			out[c.ID] = c.Result
+			continue
+		}
+		out[c.ID] = childData(c)
+	}
+	return out
+}
@@ -0,0 +1,45 @@
+// Package idempotency maps client supplied idempotency keys to the
+// execution or batch they first created, so retried requests are not run
+// twice.
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const DefaultTTL = 24 * time.Hour
+
+// ErrMismatch is returned when a key is reused for a different request.
+var ErrMismatch = errors.New("idempotency key reused with a different request")
+
+// Entry is a reserved key.
+type Entry struct {
+	Key          string    `bson:"_id"`
+	ExecutionUID string    `bson:"exuid"`       // or the batch ID of a batch request
+	Fingerprint  string    `bson:"fingerprint"` // identifies the request the key was used for
+	ExpiresAt    time.Time `bson:"expiresAt"`
+}
+
+// Store reserves idempotency keys.
+type Store interface {
+	// Reserve binds key to uid for ttl unless the key is already bound. It
+	// returns the bound execution and whether it is uid, i.e. the caller
+	// has to run the request. A live key used with another fingerprint
+	// fails with ErrMismatch.
+	Reserve(ctx context.Context, key, fingerprint string, uid uuid.UUID, ttl time.Duration) (uuid.UUID, bool, error)
+	// Release drops a key, e.g. when the request it was reserved for
+	// could not be queued and may be retried.
+	Release(ctx context.Context, key string) error
+}
+
+// existing returns the execution of a live entry found for a reservation.
+func (e *Entry) existing(fingerprint string) (uuid.UUID, error) {
+	if e.Fingerprint != fingerprint {
+		return uuid.Nil, ErrMismatch
+	}
+	return uuid.Parse(e.ExecutionUID)
+}
@@ -0,0 +1,42 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestMemoryStoreReserve(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemoryStore()
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	m.now = func() time.Time { return now }
+
+	first := uuid.New()
+	got, created, err := m.Reserve(ctx, "k", "1/2", first, time.Hour)
+	if err != nil || !created || got != first {
+		t.Fatalf("first Reserve = %v, %v, %v", got, created, err)
+	}
+	got, created, err = m.Reserve(ctx, "k", "1/2", uuid.New(), time.Hour)
+	if err != nil || created || got != first {
+		t.Fatalf("retry Reserve = %v, %v, %v; want %v, false", got, created, err, first)
+	}
+	if _, _, err := m.Reserve(ctx, "k", "1/3", uuid.New(), time.Hour); !errors.Is(err, ErrMismatch) {
+		t.Fatalf("Reserve with other request = %v, want ErrMismatch", err)
+	}
+
+	// the key can be reused once it expired or was released
+	now = now.Add(time.Hour)
+	second := uuid.New()
+	if got, created, _ := m.Reserve(ctx, "k", "1/3", second, time.Hour); !created || got != second {
+		t.Fatalf("Reserve after expiry = %v, %v", got, created)
+	}
+	m.Release(ctx, "k")
+	third := uuid.New()
+	if got, created, _ := m.Reserve(ctx, "k", "1/2", third, time.Hour); !created || got != third {
+		t.Fatalf("Reserve after release = %v, %v", got, created)
+	}
+}
@@ -0,0 +1,52 @@
+package idempotency
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var _ Store = (*MemoryStore)(nil)
+
+// MemoryStore keeps keys in memory. Keys are not shared between replicas
+// and are lost on restart.
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]Entry
+	now     func() time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string]Entry), now: time.Now}
+}
+
+func (m *MemoryStore) Reserve(_ context.Context, key, fingerprint string, uid uuid.UUID, ttl time.Duration) (uuid.UUID, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	if e, ok := m.entries[key]; ok && now.Before(e.ExpiresAt) {
+		existing, err := e.existing(fingerprint)
+		return existing, false, err
+	}
+	m.expire(now)
+	m.entries[key] = Entry{Key: key, ExecutionUID: uid.String(), Fingerprint: fingerprint, ExpiresAt: now.Add(ttl)}
+	return uid, true, nil
+}
+
+func (m *MemoryStore) Release(_ context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.entries, key)
+	return nil
+}
+
+// expire drops the expired entries.
+func (m *MemoryStore) expire(now time.Time) {
+	for k, e := range m.entries {
+		if !now.Before(e.ExpiresAt) {
+			delete(m.entries, k)
+		}
+	}
+}
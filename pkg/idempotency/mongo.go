@@ -0,0 +1,70 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var _ Store = (*MongoStore)(nil)
+
+// MongoStore keeps one document per key. A TTL index removes expired
+// keys; until it does, they are replaced on reservation.
+type MongoStore struct {
+	Collection *mongo.Collection
+}
+
+func NewMongoStore(collection *mongo.Collection) *MongoStore {
+	return &MongoStore{Collection: collection}
+}
+
+// EnsureIndexes creates the TTL index on expiresAt.
+func (m *MongoStore) EnsureIndexes(ctx context.Context) error {
+	_, err := m.Collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	return err
+}
+
+func (m *MongoStore) Reserve(ctx context.Context, key, fingerprint string, uid uuid.UUID, ttl time.Duration) (uuid.UUID, bool, error) {
+	now := time.Now().UTC()
+	entry := Entry{Key: key, ExecutionUID: uid.String(), Fingerprint: fingerprint, ExpiresAt: now.Add(ttl)}
+
+	// take over the key if it is new or expired; a live key makes the
+	// upsert collide with the existing document
+	_, err := m.Collection.ReplaceOne(ctx,
+		bson.M{"_id": key, "expiresAt": bson.M{"$lte": now}},
+		entry, options.Replace().SetUpsert(true))
+	if err == nil {
+		return uid, true, nil
+	}
+	if !mongo.IsDuplicateKeyError(err) {
+		return uuid.Nil, false, fmt.Errorf("MongoDB ReplaceOne failed: %w", err)
+	}
+
+	var existing Entry
+	err = m.Collection.FindOne(ctx, bson.M{"_id": key}).Decode(&existing)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		// released or expired in between
+		return m.Reserve(ctx, key, fingerprint, uid, ttl)
+	}
+	if err != nil {
+		return uuid.Nil, false, fmt.Errorf("MongoDB FindOne failed: %w", err)
+	}
+	bound, err := existing.existing(fingerprint)
+	return bound, false, err
+}
+
+func (m *MongoStore) Release(ctx context.Context, key string) error {
+	if _, err := m.Collection.DeleteOne(ctx, bson.M{"_id": key}); err != nil {
+		return fmt.Errorf("MongoDB DeleteOne failed: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,270 @@
+package kafkautil
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "sync"
+    "time"
+
+    "github.com/segmentio/kafka-go"
+)
+
+const (
+    DefaultRedeliveryDelay = 30 * time.Second
+    DefaultMaxDeliveries   = 5
+    commitTimeout          = 10 * time.Second
+)
+
+// ErrDropped is returned by Nack when a message reached the maximum number
+// of deliveries. It is committed and not delivered again.
+var ErrDropped = errors.New("message dropped after max deliveries")
+
+// DecodeError is returned by Fetch for a message whose value is not valid
+// JSON for the payload type. The message is committed.
+type DecodeError struct {
+    Partition int
+    Offset    int64
+    Err       error
+}
+
+func (e *DecodeError) Error() string {
+    return fmt.Sprintf("decode message %d/%d: %v", e.Partition, e.Offset, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error { return e.Err }
+
+type messageReader interface {
+    FetchMessage(ctx context.Context) (kafka.Message, error)
+    CommitMessages(ctx context.Context, msgs ...kafka.Message) error
+    Close() error
+}
+
+// Message is a fetched message awaiting Ack or Nack.
+type Message[T any] struct {
+    Value T
+    // Deliveries counts how often the message was handed out, starting at 1.
+    Deliveries int
+
+    c    *AckConsumer[T]
+    raw  kafka.Message
+    once sync.Once
+}
+
+// Ack marks the message as processed. Its offset is committed once every
+// earlier message of the partition is acked too.
+func (m *Message[T]) Ack() error {
+    var err error
+    m.once.Do(func() { err = m.c.ack(m.raw) })
+    return err
+}
+
+// Nack hands the message out again after the redelivery delay, keeping
+// its offset uncommitted meanwhile. After the maximum number of
+// deliveries it is acked instead and Nack returns ErrDropped.
+func (m *Message[T]) Nack() error {
+    var err error
+    m.once.Do(func() {
+        if m.Deliveries >= m.c.maxDeliveries {
+            if err = m.c.ack(m.raw); err == nil {
+                err = ErrDropped
+            }
+            return
+        }
+        m.c.redeliver(&Message[T]{Value: m.Value, Deliveries: m.Deliveries + 1, c: m.c, raw: m.raw})
+    })
+    return err
+}
+
+// AckConsumer is a consumer for at-least-once processing: instead of
+// committing a message when it is read, it hands it to the caller with an
+// Ack and a Nack handle. Messages may be acked in any order; a partition's
+// offset only advances past messages that are all acked, so a crash
+// redelivers everything unfinished.
+type AckConsumer[T any] struct {
+    reader          messageReader
+    redeliveryDelay time.Duration
+    maxDeliveries   int
+
+    fetchOnce sync.Once
+    fetched   chan fetchResult
+    retry     chan *Message[T]
+    done      chan struct{}
+    closeOnce sync.Once
+
+    mu      sync.Mutex
+    offsets map[int]*partitionOffsets
+}
+
+type fetchResult struct {
+    msg kafka.Message
+    err error
+}
+
+func NewAckConsumer[T any](cfg Config) *AckConsumer[T] {
+    r := kafka.NewReader(kafka.ReaderConfig{
+        Brokers: cfg.Brokers,
+        GroupID: cfg.GroupID,
+        Topic:   cfg.Topic,
+    })
+    return newAckConsumer[T](r, cfg)
+}
+
+func newAckConsumer[T any](r messageReader, cfg Config) *AckConsumer[T] {
+    c := &AckConsumer[T]{
+        reader:          r,
+        redeliveryDelay: cfg.RedeliveryDelay,
+        maxDeliveries:   cfg.MaxDeliveries,
+        fetched:         make(chan fetchResult),
+        retry:           make(chan *Message[T]),
+        done:            make(chan struct{}),
+        offsets:         make(map[int]*partitionOffsets),
+    }
+    if c.redeliveryDelay <= 0 {
+        c.redeliveryDelay = DefaultRedeliveryDelay
+    }
+    if c.maxDeliveries <= 0 {
+        c.maxDeliveries = DefaultMaxDeliveries
+    }
+    return c
+}
+
+// Fetch returns the next message, a redelivered one if any is due.
+func (c *AckConsumer[T]) Fetch(ctx context.Context) (*Message[T], error) {
+    c.fetchOnce.Do(func() { go c.fetchLoop() })
+    select {
+    case <-ctx.Done():
+        return nil, ctx.Err()
+    case <-c.done:
+        return nil, errors.New("consumer closed")
+    case m := <-c.retry:
+        return m, nil
+    case res := <-c.fetched:
+        if res.err != nil {
+            return nil, res.err
+        }
+        c.track(res.msg)
+        m := &Message[T]{c: c, raw: res.msg, Deliveries: 1}
+        if err := json.Unmarshal(res.msg.Value, &m.Value); err != nil {
+            // it will never decode, do not hold the partition back
+            m.Ack()
+            return nil, &DecodeError{Partition: res.msg.Partition, Offset: res.msg.Offset, Err: err}
+        }
+        return m, nil
+    }
+}
+
+// fetchLoop reads from Kafka one message at a time, whenever Fetch is
+// ready to take it.
+func (c *AckConsumer[T]) fetchLoop() {
+    ctx, cancel := context.WithCancel(context.Background())
+    defer cancel()
+    go func() {
+        <-c.done
+        cancel()
+    }()
+    for {
+        msg, err := c.reader.FetchMessage(ctx)
+        if ctx.Err() != nil {
+            return
+        }
+        select {
+        case c.fetched <- fetchResult{msg: msg, err: err}:
+        case <-c.done:
+            return
+        }
+        if err != nil {
+            // do not spin on a persistent error
+            select {
+            case <-time.After(100 * time.Millisecond):
+            case <-c.done:
+                return
+            }
+        }
+    }
+}
+
+func (c *AckConsumer[T]) redeliver(m *Message[T]) {
+    time.AfterFunc(c.redeliveryDelay, func() {
+        select {
+        case c.retry <- m:
+        case <-c.done:
+        }
+    })
+}
+
+// track records a fetched message as outstanding.
+func (c *AckConsumer[T]) track(msg kafka.Message) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    p, ok := c.offsets[msg.Partition]
+    if !ok {
+        p = &partitionOffsets{}
+        c.offsets[msg.Partition] = p
+    }
+    p.track(msg)
+}
+
+// ack marks msg as done and commits the partition up to the last message
+// before the first outstanding one.
+func (c *AckConsumer[T]) ack(msg kafka.Message) error {
+    c.mu.Lock()
+    p, ok := c.offsets[msg.Partition]
+    var commit *kafka.Message
+    if ok {
+        commit = p.ack(msg.Offset)
+    }
+    c.mu.Unlock()
+    if commit == nil {
+        return nil
+    }
+    ctx, cancel := context.WithTimeout(context.Background(), commitTimeout)
+    defer cancel()
+    return c.reader.CommitMessages(ctx, *commit)
+}
+
+// Close stops fetching and redelivering. Acks after Close are not
+// committed.
+func (c *AckConsumer[T]) Close() error {
+    c.closeOnce.Do(func() { close(c.done) })
+    return c.reader.Close()
+}
+
+// partitionOffsets holds the outstanding messages of one partition in
+// offset order.
+type partitionOffsets struct {
+    pending []pendingOffset
+}
+
+type pendingOffset struct {
+    msg  kafka.Message
+    done bool
+}
+
+func (p *partitionOffsets) track(msg kafka.Message) {
+    if n := len(p.pending); n > 0 && msg.Offset <= p.pending[n-1].msg.Offset {
+        // the reader went back, e.g. after a rebalance; everything
+        // outstanding is fetched again
+        p.pending = nil
+    }
+    p.pending = append(p.pending, pendingOffset{msg: msg})
+}
+
+// ack marks offset as done and returns the message to commit, if the
+// committed position can advance.
+func (p *partitionOffsets) ack(offset int64) *kafka.Message {
+    for i := range p.pending {
+        if p.pending[i].msg.Offset == offset {
+            p.pending[i].done = true
+            break
+        }
+    }
+    var commit *kafka.Message
+    for len(p.pending) > 0 && p.pending[0].done {
+        msg := p.pending[0].msg
+        commit = &msg
+        p.pending = p.pending[1:]
+    }
+    return commit
+}
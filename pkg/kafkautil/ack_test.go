@@ -0,0 +1,149 @@
+package kafkautil
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+type fakeReader struct {
+	msgs chan kafka.Message
+
+	mu        sync.Mutex
+	committed map[int]int64 // partition to last committed offset
+}
+
+func newFakeReader(msgs ...kafka.Message) *fakeReader {
+	r := &fakeReader{msgs: make(chan kafka.Message, len(msgs)), committed: make(map[int]int64)}
+	for _, m := range msgs {
+		r.msgs <- m
+	}
+	return r
+}
+
+func (r *fakeReader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	select {
+	case m := <-r.msgs:
+		return m, nil
+	case <-ctx.Done():
+		return kafka.Message{}, ctx.Err()
+	}
+}
+
+func (r *fakeReader) CommitMessages(_ context.Context, msgs ...kafka.Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, m := range msgs {
+		r.committed[m.Partition] = m.Offset
+	}
+	return nil
+}
+
+func (r *fakeReader) Close() error { return nil }
+
+func (r *fakeReader) commit(partition int) (int64, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	off, ok := r.committed[partition]
+	return off, ok
+}
+
+func msg(partition int, offset int64, value string) kafka.Message {
+	return kafka.Message{Partition: partition, Offset: offset, Value: []byte(value)}
+}
+
+type payload struct {
+	ID int `json:"id"`
+}
+
+func fetch(t *testing.T, c *AckConsumer[payload]) *Message[payload] {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	m, err := c.Fetch(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestAckConsumerCommitsContiguousOffsets(t *testing.T) {
+	r := newFakeReader(
+		msg(0, 10, `{"id":1}`),
+		msg(0, 11, `{"id":2}`),
+		msg(1, 5, `{"id":3}`),
+		msg(0, 12, `{"id":4}`),
+	)
+	c := newAckConsumer[payload](r, Config{})
+	defer c.Close()
+
+	var got []*Message[payload]
+	for i := 0; i < 4; i++ {
+		got = append(got, fetch(t, c))
+	}
+
+	// acking out of order does not commit past an outstanding message
+	got[1].Ack()
+	got[3].Ack()
+	if _, ok := r.commit(0); ok {
+		t.Fatal("committed partition 0 while offset 10 is outstanding")
+	}
+	got[2].Ack()
+	if off, _ := r.commit(1); off != 5 {
+		t.Errorf("partition 1 committed %d, want 5", off)
+	}
+	got[0].Ack()
+	if off, _ := r.commit(0); off != 12 {
+		t.Errorf("partition 0 committed %d, want 12", off)
+	}
+}
+
+func TestAckConsumerNackRedelivers(t *testing.T) {
+	r := newFakeReader(msg(0, 1, `{"id":7}`), msg(0, 2, `{"id":8}`))
+	c := newAckConsumer[payload](r, Config{RedeliveryDelay: time.Millisecond, MaxDeliveries: 2})
+	defer c.Close()
+
+	first := fetch(t, c)
+	second := fetch(t, c)
+	if err := first.Nack(); err != nil {
+		t.Fatal(err)
+	}
+	second.Ack()
+	if _, ok := r.commit(0); ok {
+		t.Fatal("committed past a nacked message")
+	}
+
+	again := fetch(t, c)
+	if again.Value.ID != 7 || again.Deliveries != 2 {
+		t.Fatalf("redelivered %+v, deliveries %d", again.Value, again.Deliveries)
+	}
+	// the last delivery is dropped and committed
+	if err := again.Nack(); !errors.Is(err, ErrDropped) {
+		t.Fatalf("Nack at max deliveries = %v, want ErrDropped", err)
+	}
+	if off, _ := r.commit(0); off != 2 {
+		t.Errorf("committed %d, want 2", off)
+	}
+}
+
+func TestAckConsumerDecodeError(t *testing.T) {
+	r := newFakeReader(msg(0, 3, `not json`), msg(0, 4, `{"id":1}`))
+	c := newAckConsumer[payload](r, Config{})
+	defer c.Close()
+
+	var decodeErr *DecodeError
+	if _, err := c.Fetch(context.Background()); !errors.As(err, &decodeErr) || decodeErr.Offset != 3 {
+		t.Fatalf("Fetch = %v, want DecodeError at offset 3", err)
+	}
+	if off, _ := r.commit(0); off != 3 {
+		t.Errorf("undecodable message not committed, got %d", off)
+	}
+	fetch(t, c).Ack()
+	if off, _ := r.commit(0); off != 4 {
+		t.Errorf("committed %d, want 4", off)
+	}
+}
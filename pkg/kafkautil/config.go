@@ -1,8 +1,13 @@
-
 package kafkautil
 
+import "time"
+
 type Config struct {
     Brokers   []string
     Topic     string
     GroupID   string
-}
\ No newline at end of file
+
+    // RedeliveryDelay and MaxDeliveries apply to AckConsumer.
+    RedeliveryDelay time.Duration
+    MaxDeliveries   int
+}
@@ -1,10 +1,16 @@
-// Package processor provides a modular framework for processing string slices
-// with configurable processor chains.
+// Package processor provides a modular framework for turning script output
+// into typed values with configurable processor chains.
+//
+// Values use the types encoding/json decodes into: string, float64, bool,
+// nil, []any and map[string]any, so they are stored as native JSON and
+// BSON documents.
 package processor
 
 import (
 	"encoding/json"
 	"fmt"
+	"math"
+	"strconv"
 	"strings"
 )
 
@@ -21,12 +27,14 @@ const (
 	ProcessorTypeKeyValue 		string = "key_value"
 	ProcessorJSONTypeKeyValue	string = "key_value_json"
 	ProcessorTypeSplitLines		string = "split_lines"
+	ProcessorTypeJSON			string = "json"
+	ProcessorTypeConvert		string = "convert"
 )
 
-// Processor defines the interface for processing string slices.
+// Processor defines the interface for processing values.
 type Processor interface {
-	// Process applies the processor's logic to the input lines.
-	Process([]string, NodeType) ([]string, error)
+	// Process applies the processor's logic to the input value.
+	Process(any, NodeType) (any, error)
 	Name() string
 }
 
@@ -49,6 +57,9 @@ func (pc *ProcessorChain) registerDefaults() {
 	pc.Register(&TrimProcessor{})
 	pc.Register(&SplitLinesProcessor{})
 	pc.Register(&KeyValueProcessor{})
+	pc.Register(&KeyValueJSONProcessor{})
+	pc.Register(&JSONProcessor{})
+	pc.Register(&ConvertProcessor{})
 }
 
 // Register adds a processor to the chain.
@@ -60,7 +71,23 @@ func isValidNodeType(nt NodeType) bool {
     return nt == NodeTypeObject || nt == NodeTypeString || nt == NodeTypeArray
 }
 
-func (pc *ProcessorChain) Process(lines []string,nodeType NodeType,processorNames ...string,) ([]string, error) {
+// Lines is the value processors start from: array nodes get a list with
+// one string per line, other nodes the output as a single string.
+func Lines(lines []string, nodeType NodeType) any {
+	if len(lines) == 0 {
+		return nil
+	}
+	if nodeType == NodeTypeArray {
+		list := make([]any, len(lines))
+		for i, line := range lines {
+			list[i] = line
+		}
+		return list
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (pc *ProcessorChain) Process(lines []string,nodeType NodeType,processorNames ...string,) (any, error) {
 	if !isValidNodeType(nodeType) {
         return nil, fmt.Errorf("invalid nodeType: %v", nodeType)
     }
@@ -70,9 +97,9 @@ func (pc *ProcessorChain) Process(lines []string,nodeType NodeType,processorName
         }
     }
     if len(lines) == 0 {
-        return lines, nil
+        return nil, nil
     }
-	result := lines
+	result := Lines(lines, nodeType)
     // Apply processors in specified order
     for _, name := range processorNames {
 		var err error
@@ -84,38 +111,70 @@ func (pc *ProcessorChain) Process(lines []string,nodeType NodeType,processorName
         if err != nil {
             return nil, fmt.Errorf("%s processor failed: %w", name, err)
         }
-        if len(result) == 0 && !pc.allowEmptyResults{
+        if result == nil && !pc.allowEmptyResults{
             break
         }
     }
     return result, nil
 }
 
+// textLines returns the lines of a string or of a list of strings.
+func textLines(v any) ([]string, bool) {
+	switch t := v.(type) {
+	case string:
+		return strings.Split(t, "\n"), true
+	case []any:
+		lines := make([]string, 0, len(t))
+		for _, item := range t {
+			s, ok := item.(string)
+			if !ok {
+				return nil, false
+			}
+			lines = append(lines, s)
+		}
+		return lines, true
+	}
+	return nil, false
+}
+
+// mapStrings applies fn to every string in v, descending into lists and objects.
+func mapStrings(v any, fn func(string) any) any {
+	switch t := v.(type) {
+	case string:
+		return fn(t)
+	case []any:
+		out := make([]any, len(t))
+		for i, item := range t {
+			out[i] = mapStrings(item, fn)
+		}
+		return out
+	case map[string]any:
+		out := make(map[string]any, len(t))
+		for k, item := range t {
+			out[k] = mapStrings(item, fn)
+		}
+		return out
+	}
+	return v
+}
+
 //Processor Implementations
 
-// TrimProcessor trims whitespace from each line in the input.
+// TrimProcessor trims whitespace from every string in the value.
 type TrimProcessor struct{}
 
 func (p *TrimProcessor) Name() string { return ProcessorTypeTrim }
-func (p *TrimProcessor) Process(lines []string, _ NodeType) ([]string, error) {
-	trimmed := make([]string, len(lines))
-	for i, line := range lines {
-		trimmed[i] = strings.TrimSpace(line)
-	}
-	return trimmed, nil
+func (p *TrimProcessor) Process(v any, _ NodeType) (any, error) {
+	return mapStrings(v, func(s string) any { return strings.TrimSpace(s) }), nil
+}
+
+func (p *TrimProcessor) ProcessLine(line string, _ NodeType) ([]string, error) {
+	return []string{strings.TrimSpace(line)}, nil
 }
 
 // KeyValueProcessor handles string nodes with key:value format
-func parseKeyValueLines(lines []string) (map[string]string, error) {
-    kv := make(map[string]string)
-    
-    // Handle case where input is a single string with embedded newlines
-    if len(lines) == 1 {
-        inputLines := strings.Split(strings.TrimSpace(lines[0]), "\n")
-        if len(inputLines) > 1 {
-            lines = inputLines
-        }
-    }
+func parseKeyValueLines(lines []string) (map[string]any, error) {
+    kv := make(map[string]any)
 
     for _, line := range lines {
         parts := strings.SplitN(strings.TrimSpace(line), ":", 2)
@@ -132,61 +191,87 @@ func parseKeyValueLines(lines []string) (map[string]string, error) {
     return kv, nil
 }
 
+// KeyValueProcessor turns "key: value" lines into an object, whatever the
+// node type; array nodes become objects too.
 type KeyValueProcessor struct{}
 
 func (p *KeyValueProcessor) Name() string { return ProcessorTypeKeyValue }
 
-func (p *KeyValueProcessor) Process(lines []string, nodeType NodeType) ([]string, error) {
-    if nodeType != NodeTypeString || len(lines) == 0 {
-        return lines, nil
-    }
-    
-    kv, err := parseKeyValueLines(lines)
-    if err != nil {
-        return nil, err
+func (p *KeyValueProcessor) Process(v any, nodeType NodeType) (any, error) {
+    lines, ok := textLines(v)
+    if !ok {
+        return v, nil
     }
-
-    result := make([]string, 0, len(kv))
-    for k, v := range kv {
-        result = append(result, fmt.Sprintf("%s: %s", k, v))
-    }
-    return result, nil
+    return parseKeyValueLines(lines)
 }
 
-// KeyValueProcessor handles string nodes with key:value format
-type KeyValueJSONProcessor struct{}
+// KeyValueJSONProcessor is kept for scripts written when results were
+// strings; it produces the same object as KeyValueProcessor.
+type KeyValueJSONProcessor struct{ KeyValueProcessor }
 
-func (p *KeyValueJSONProcessor) Name() string { return ProcessorTypeKeyValue }
-
-func (p *KeyValueJSONProcessor) Process(lines []string, nodeType NodeType) ([]string, error) {
-    if nodeType != NodeTypeString || len(lines) == 0 {
-        return lines, nil
-    }
-    
-    kv, err := parseKeyValueLines(lines)
-    if err != nil {
-        return nil, err
-    }
-
-    result, err := json.Marshal(kv)
-    if err != nil {
-        return nil, fmt.Errorf("key_value marshal error: %w", err)
-    }
-    return []string{string(result)}, nil
-}
+func (p *KeyValueJSONProcessor) Name() string { return ProcessorJSONTypeKeyValue }
 
 // SplitLinesProcessor splits each line into fields for array node types.
 type SplitLinesProcessor struct{}
 
 func (p *SplitLinesProcessor) Name() string { return ProcessorTypeSplitLines }
 
-func (p *SplitLinesProcessor) Process(lines []string, nodeType NodeType) ([]string, error) {
+func (p *SplitLinesProcessor) Process(v any, nodeType NodeType) (any, error) {
     if nodeType != NodeTypeArray {
-        return lines, nil
+        return v, nil
+    }
+    lines, ok := textLines(v)
+    if !ok {
+        return v, nil
     }
-	result := make([]string, 0, len(lines)*3)
+	result := make([]any, 0, len(lines)*3)
     for _, line := range lines {
-        result = append(result, strings.Fields(line)...)
+        for _, f := range strings.Fields(line) {
+            result = append(result, f)
+        }
     }
     return result, nil
-}
\ No newline at end of file
+}
+
+func (p *SplitLinesProcessor) ProcessLine(line string, nodeType NodeType) ([]string, error) {
+    if nodeType != NodeTypeArray {
+        return []string{line}, nil
+    }
+    return strings.Fields(line), nil
+}
+
+// JSONProcessor decodes output that is a JSON document, e.g. lsblk -J.
+type JSONProcessor struct{}
+
+func (p *JSONProcessor) Name() string { return ProcessorTypeJSON }
+
+func (p *JSONProcessor) Process(v any, _ NodeType) (any, error) {
+    lines, ok := textLines(v)
+    if !ok {
+        return v, nil
+    }
+    var out any
+    if err := json.Unmarshal([]byte(strings.Join(lines, "\n")), &out); err != nil {
+        return nil, fmt.Errorf("invalid json output: %w", err)
+    }
+    return out, nil
+}
+
+// ConvertProcessor turns strings holding numbers or booleans into
+// float64 and bool values.
+type ConvertProcessor struct{}
+
+func (p *ConvertProcessor) Name() string { return ProcessorTypeConvert }
+
+func (p *ConvertProcessor) Process(v any, _ NodeType) (any, error) {
+	return mapStrings(v, func(s string) any {
+		t := strings.TrimSpace(s)
+		if f, err := strconv.ParseFloat(t, 64); err == nil && !math.IsInf(f, 0) && !math.IsNaN(f) {
+			return f
+		}
+		if b, err := strconv.ParseBool(t); err == nil && (t == "true" || t == "false") {
+			return b
+		}
+		return s
+	}), nil
+}
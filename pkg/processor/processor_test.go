@@ -7,9 +7,9 @@ import (
 
 func TestTrimProcessor(t *testing.T) {
     p := &TrimProcessor{}
-    input := []string{"  hello    ", " world "}
-    expected := []string{"hello", "world"}
-    result, err := p.Process(input, NodeTypeString)
+    input := []any{"  hello    ", " world "}
+    expected := []any{"hello", "world"}
+    result, err := p.Process(input, NodeTypeArray)
     if err != nil {
         t.Fatalf("TrimProcessor failed: %v", err)
     }
@@ -20,8 +20,8 @@ func TestTrimProcessor(t *testing.T) {
 
 func TestKeyValueProcessor(t *testing.T) {
     p := &KeyValueProcessor{}
-    input := []string{" key1: value1 ", " key2: value2 "}
-    expected := []string{"key1: value1", "key2: value2"}
+    input := " key1: value1 \n key2: value2 "
+    expected := map[string]any{"key1": "value1", "key2": "value2"}
     result, err := p.Process(input, NodeTypeString)
     if err != nil {
         t.Fatalf("KeyValueProcessor failed: %v", err)
@@ -34,7 +34,7 @@ func TestKeyValueProcessor(t *testing.T) {
 func TestProcessorChain(t *testing.T) {
     pc := NewProcessorChain()
     input := []string{"key1: value1 ", "key2: value2 "}
-    expected := []string{"key1: value1", "key2: value2"}
+    expected := map[string]any{"key1": "value1", "key2": "value2"}
     result, err := pc.Process(input, NodeTypeString, ProcessorTypeTrim, ProcessorTypeKeyValue)
     if err != nil {
         t.Fatalf("ProcessorChain failed: %v", err)
@@ -49,17 +49,17 @@ func TestProcessorChainEdgeCases(t *testing.T) {
     tests := []struct {
         name     string
         input    []string
-        expected []string
+        expected any
     }{
         {
             name:     "single string with newlines",
             input:    []string{"  key1: value1\n    key2: value2  "},
-            expected: []string{"key1: value1", "key2: value2"},
+            expected: map[string]any{"key1": "value1", "key2": "value2"},
         },
         {
             name:     "empty input",
             input:    []string{},
-            expected: []string{},
+            expected: nil,
         },
     }
     for _, tt := range tests {
@@ -74,3 +74,63 @@ func TestProcessorChainEdgeCases(t *testing.T) {
         })
     }
 }
+
+func TestTypedValues(t *testing.T) {
+    pc := NewProcessorChain()
+    tests := []struct {
+        name       string
+        input      []string
+        nodeType   NodeType
+        processors []string
+        expected   any
+    }{
+        {
+            name:     "string without processors",
+            input:    []string{"6.1.0-18-amd64"},
+            nodeType: NodeTypeString,
+            expected: "6.1.0-18-amd64",
+        },
+        {
+            name:     "array keeps lines",
+            input:    []string{"sda", "sdb"},
+            nodeType: NodeTypeArray,
+            expected: []any{"sda", "sdb"},
+        },
+        {
+            name:       "key value with converted numbers",
+            input:      []string{"CPU(s): 8", "Architecture: x86_64", "NUMA: true"},
+            nodeType:   NodeTypeArray,
+            processors: []string{ProcessorTypeKeyValue, ProcessorTypeConvert},
+            expected:   map[string]any{"CPU(s)": 8.0, "Architecture": "x86_64", "NUMA": true},
+        },
+        {
+            name:       "json document",
+            input:      []string{`{"blockdevices": [`, `{"name": "sda", "size": 512}]}`},
+            nodeType:   NodeTypeString,
+            processors: []string{ProcessorTypeJSON},
+            expected:   map[string]any{"blockdevices": []any{map[string]any{"name": "sda", "size": 512.0}}},
+        },
+        {
+            name:       "split lines",
+            input:      []string{"a b", "c"},
+            nodeType:   NodeTypeArray,
+            processors: []string{ProcessorTypeSplitLines},
+            expected:   []any{"a", "b", "c"},
+        },
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            result, err := pc.Process(tt.input, tt.nodeType, tt.processors...)
+            if err != nil {
+                t.Fatalf("ProcessorChain failed: %v", err)
+            }
+            if !reflect.DeepEqual(result, tt.expected) {
+                t.Errorf("got %#v, want %#v", result, tt.expected)
+            }
+        })
+    }
+
+    if _, err := pc.Process([]string{"not json"}, NodeTypeString, ProcessorTypeJSON); err == nil {
+        t.Error("expected error for invalid json")
+    }
+}
@@ -0,0 +1,87 @@
+package processor
+
+import "fmt"
+
+// LineProcessor is implemented by processors that can work on one line at
+// a time. ProcessLine returns the lines to pass on; none drops the line.
+type LineProcessor interface {
+	ProcessLine(line string, nodeType NodeType) ([]string, error)
+}
+
+// Stream feeds output lines to a processor chain while a script runs.
+// For array nodes the leading processors that implement LineProcessor are
+// applied to every line as it arrives; the remaining processors run on
+// the collected value in Result. Other node types are processed as a
+// whole, so the result matches ProcessorChain.Process for the same output.
+type Stream struct {
+	nodeType NodeType
+	line     []Processor // all implement LineProcessor
+	rest     []Processor
+	lines    []string
+	err      error
+}
+
+// NewStream checks the node type and processor names like Process does.
+func (pc *ProcessorChain) NewStream(nodeType NodeType, processorNames ...string) (*Stream, error) {
+	if !isValidNodeType(nodeType) {
+		return nil, fmt.Errorf("invalid nodeType: %v", nodeType)
+	}
+	s := &Stream{nodeType: nodeType}
+	for _, name := range processorNames {
+		p, exists := pc.processors[name]
+		if !exists {
+			return nil, fmt.Errorf("processor %q not registered", name)
+		}
+		if _, ok := p.(LineProcessor); ok && nodeType == NodeTypeArray && len(s.rest) == 0 {
+			s.line = append(s.line, p)
+			continue
+		}
+		s.rest = append(s.rest, p)
+	}
+	return s, nil
+}
+
+// Add processes one output line.
+func (s *Stream) Add(line string) {
+	if s.err != nil {
+		return
+	}
+	lines := []string{line}
+	for _, p := range s.line {
+		var next []string
+		for _, l := range lines {
+			out, err := p.(LineProcessor).ProcessLine(l, s.nodeType)
+			if err != nil {
+				s.err = fmt.Errorf("%s processor failed: %w", p.Name(), err)
+				return
+			}
+			next = append(next, out...)
+		}
+		lines = next
+	}
+	s.lines = append(s.lines, lines...)
+}
+
+// Lines returns the lines collected so far, after line processing.
+func (s *Stream) Lines() []string {
+	return s.lines
+}
+
+// Result applies the remaining processors and returns the value.
+func (s *Stream) Result() (any, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	if len(s.lines) == 0 {
+		return nil, nil
+	}
+	result := Lines(s.lines, s.nodeType)
+	for _, p := range s.rest {
+		var err error
+		result, err = p.Process(result, s.nodeType)
+		if err != nil {
+			return nil, fmt.Errorf("%s processor failed: %w", p.Name(), err)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,48 @@
+package processor
+
+import (
+    "reflect"
+    "testing"
+)
+
+func TestStreamMatchesProcess(t *testing.T) {
+    pc := NewProcessorChain()
+    tests := []struct {
+        name       string
+        input      []string
+        nodeType   NodeType
+        processors []string
+    }{
+        {"array line processors", []string{" a b ", "c  "}, NodeTypeArray, []string{ProcessorTypeTrim, ProcessorTypeSplitLines}},
+        {"array key value", []string{"Architecture: x86_64 ", "CPU(s): 8"}, NodeTypeArray, []string{ProcessorTypeTrim, ProcessorTypeKeyValue, ProcessorTypeConvert}},
+        {"string trim", []string{"  total used", "  Mem: 1 2  "}, NodeTypeString, []string{ProcessorTypeTrim}},
+        {"json", []string{`{"a":`, `[1, 2]}`}, NodeTypeString, []string{ProcessorTypeJSON}},
+        {"empty", nil, NodeTypeArray, []string{ProcessorTypeTrim}},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            want, err := pc.Process(tt.input, tt.nodeType, tt.processors...)
+            if err != nil {
+                t.Fatalf("Process: %v", err)
+            }
+            s, err := pc.NewStream(tt.nodeType, tt.processors...)
+            if err != nil {
+                t.Fatalf("NewStream: %v", err)
+            }
+            for _, line := range tt.input {
+                s.Add(line)
+            }
+            got, err := s.Result()
+            if err != nil {
+                t.Fatalf("Result: %v", err)
+            }
+            if !reflect.DeepEqual(got, want) {
+                t.Errorf("stream %#v, process %#v", got, want)
+            }
+        })
+    }
+
+    if _, err := pc.NewStream(NodeTypeArray, "missing"); err == nil {
+        t.Error("expected error for unknown processor")
+    }
+}
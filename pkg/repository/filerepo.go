@@ -0,0 +1,99 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	gp "github.com/andrej220/HAM/pkg/graphproc"
+)
+
+var (
+	_ ScriptRepository = (*FileScriptRepository)(nil)
+	_ HostRepository   = (*FileHostRepository)(nil)
+	_ HostSelector     = (*FileHostRepository)(nil)
+)
+
+// FileScriptRepository reads scripts from <Dir>/<scriptId>.json.
+// Intended for development and tests.
+type FileScriptRepository struct {
+	Dir string
+}
+
+func NewFileScriptRepository(dir string) *FileScriptRepository {
+	return &FileScriptRepository{Dir: dir}
+}
+
+func (r *FileScriptRepository) GetScript(_ context.Context, scriptID int) (*gp.Config, error) {
+	var cfg gp.Config
+	if err := readJSONFile(r.Dir, scriptID, &cfg); err != nil {
+		return nil, fmt.Errorf("script %d: %w", scriptID, err)
+	}
+	return &cfg, nil
+}
+
+// FileHostRepository reads host configurations from <Dir>/<hostId>.json.
+type FileHostRepository struct {
+	Dir string
+}
+
+func NewFileHostRepository(dir string) *FileHostRepository {
+	return &FileHostRepository{Dir: dir}
+}
+
+func (r *FileHostRepository) GetHost(_ context.Context, hostID int) (*gp.HostConfig, error) {
+	var host gp.HostConfig
+	if err := readJSONFile(r.Dir, hostID, &host); err != nil {
+		return nil, fmt.Errorf("host %d: %w", hostID, err)
+	}
+	return &host, nil
+}
+
+// FindHosts reads every host file in Dir and returns the IDs of those
+// matching q.
+func (r *FileHostRepository) FindHosts(_ context.Context, q HostQuery) ([]int, error) {
+	entries, err := os.ReadDir(r.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read host directory: %w", err)
+	}
+	var ids []int
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), ".json")
+		if !ok || e.IsDir() {
+			continue
+		}
+		id, err := strconv.Atoi(name)
+		if err != nil {
+			continue
+		}
+		var host gp.HostConfig
+		if err := readJSONFile(r.Dir, id, &host); err != nil {
+			return nil, fmt.Errorf("host %d: %w", id, err)
+		}
+		if q.Matches(&host) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Ints(ids)
+	return ids, nil
+}
+
+func readJSONFile(dir string, id int, out any) error {
+	data, err := os.ReadFile(filepath.Join(dir, strconv.Itoa(id)+".json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read file: %w", err)
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("failed to unmarshal JSON: %w", err)
+	}
+	return nil
+}
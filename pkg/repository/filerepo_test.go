@@ -0,0 +1,129 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// writeFiles writes name -> content into a new directory.
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestFileRepositories(t *testing.T) {
+	scripts := NewFileScriptRepository(writeFiles(t, map[string]string{
+		"1.json": `{"version":"1.0","structure":{"id":"root","children":[{"id":"os","type":"string","script":"uname"}]}}`,
+		"2.json": `{not json`,
+	}))
+	hosts := NewFileHostRepository(writeFiles(t, map[string]string{
+		"7.json": `{"customerId":3,"hostip":"10.0.0.7","hostport":2222,"hostuser":"ops"}`,
+	}))
+	ctx := context.Background()
+
+	cfg, err := scripts.GetScript(ctx, 1)
+	if err != nil {
+		t.Fatalf("GetScript: %v", err)
+	}
+	if cfg.Structure == nil || len(cfg.Structure.Children) != 1 || cfg.Structure.Children[0].Script != "uname" {
+		t.Errorf("script structure = %+v", cfg.Structure)
+	}
+	if _, err := scripts.GetScript(ctx, 9); !errors.Is(err, ErrNotFound) {
+		t.Errorf("missing script: err = %v", err)
+	}
+	if _, err := scripts.GetScript(ctx, 2); err == nil || errors.Is(err, ErrNotFound) {
+		t.Errorf("broken script: err = %v", err)
+	}
+
+	host, err := hosts.GetHost(ctx, 7)
+	if err != nil {
+		t.Fatalf("GetHost: %v", err)
+	}
+	if host.CustomerID != 3 || host.HostIP != "10.0.0.7" || host.HostUser != "ops" {
+		t.Errorf("host = %+v", host)
+	}
+	if _, err := hosts.GetHost(ctx, 8); !errors.Is(err, ErrNotFound) {
+		t.Errorf("missing host: err = %v", err)
+	}
+}
+
+func TestLoadGraph(t *testing.T) {
+	scripts := NewFileScriptRepository(writeFiles(t, map[string]string{
+		"1.json": `{"structure":{"id":"root"}}`,
+		"2.json": `{"remote_host":"192.0.2.1:22","login":"andrey","structure":{"id":"root"}}`,
+	}))
+	hosts := NewFileHostRepository(writeFiles(t, map[string]string{
+		"7.json": `{"hostip":"10.0.0.7","hostport":2222}`,
+	}))
+	ctx := context.Background()
+
+	graph, err := LoadGraph(ctx, scripts, hosts, 1, 7, false)
+	if err != nil {
+		t.Fatalf("LoadGraph: %v", err)
+	}
+	if graph.HostCfg.HostID != 7 || graph.HostCfg.ScriptID != 1 || graph.RemoteAddr() != "10.0.0.7:2222" {
+		t.Errorf("host %+v, address %q", graph.HostCfg, graph.RemoteAddr())
+	}
+
+	// without a host document the inline host of the script is only used
+	// when asked for
+	if _, err := LoadGraph(ctx, scripts, hosts, 2, 8, false); !errors.Is(err, ErrNotFound) {
+		t.Errorf("missing host with inline host: err = %v", err)
+	}
+	graph, err = LoadGraph(ctx, scripts, hosts, 2, 8, true)
+	if err != nil {
+		t.Fatalf("LoadGraph inline: %v", err)
+	}
+	if graph.HostCfg.HostID != 8 || graph.HostCfg.ScriptID != 2 || graph.RemoteAddr() != "192.0.2.1:22" {
+		t.Errorf("inline: host %+v, address %q", graph.HostCfg, graph.RemoteAddr())
+	}
+
+	if _, err := LoadGraph(ctx, scripts, hosts, 1, 8, true); !errors.Is(err, ErrNotFound) {
+		t.Errorf("missing host without inline host: err = %v", err)
+	}
+	if _, err := LoadGraph(ctx, scripts, hosts, 3, 7, false); !errors.Is(err, ErrNotFound) {
+		t.Errorf("missing script: err = %v", err)
+	}
+}
+
+func TestFileHostRepositoryFindHosts(t *testing.T) {
+	repo := NewFileHostRepository(writeFiles(t, map[string]string{
+		"1.json":     `{"host":"a","groups":["core"],"labels":{"site":"ber","role":"router"}}`,
+		"2.json":     `{"host":"b","groups":["core","edge"],"labels":{"site":"muc"}}`,
+		"3.json":     `{"host":"c","labels":{"site":"ber"}}`,
+		"notes.txt":  `not a host`,
+		"other.json": `{"host":"d","groups":["core"]}`,
+	}))
+
+	tests := []struct {
+		name string
+		q    HostQuery
+		want []int
+	}{
+		{"group", HostQuery{Group: "core"}, []int{1, 2}},
+		{"label", HostQuery{Labels: map[string]string{"site": "ber"}}, []int{1, 3}},
+		{"group and labels", HostQuery{Group: "core", Labels: map[string]string{"site": "ber", "role": "router"}}, []int{1}},
+		{"no match", HostQuery{Group: "dmz"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := repo.FindHosts(context.Background(), tt.q)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("FindHosts = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+
+	gp "github.com/andrej220/HAM/pkg/graphproc"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	_ ScriptRepository = (*MongoScriptRepository)(nil)
+	_ HostRepository   = (*MongoHostRepository)(nil)
+	_ HostSelector     = (*MongoHostRepository)(nil)
+)
+
+// MongoScriptRepository looks scripts up by their scriptId field.
+// The id may be stored either as a string or as a number.
+type MongoScriptRepository struct {
+	Collection *mongo.Collection
+}
+
+func NewMongoScriptRepository(collection *mongo.Collection) *MongoScriptRepository {
+	return &MongoScriptRepository{Collection: collection}
+}
+
+func (r *MongoScriptRepository) GetScript(ctx context.Context, scriptID int) (*gp.Config, error) {
+	var cfg gp.Config
+	if err := findJSONDocument(ctx, r.Collection, idFilter("scriptId", scriptID), &cfg, "_id"); err != nil {
+		return nil, fmt.Errorf("script %d: %w", scriptID, err)
+	}
+	return &cfg, nil
+}
+
+// MongoHostRepository looks host configurations up by their hostId field.
+type MongoHostRepository struct {
+	Collection *mongo.Collection
+}
+
+func NewMongoHostRepository(collection *mongo.Collection) *MongoHostRepository {
+	return &MongoHostRepository{Collection: collection}
+}
+
+func (r *MongoHostRepository) GetHost(ctx context.Context, hostID int) (*gp.HostConfig, error) {
+	var host gp.HostConfig
+	if err := findJSONDocument(ctx, r.Collection, idFilter("hostId", hostID), &host, "_id", "hostId"); err != nil {
+		return nil, fmt.Errorf("host %d: %w", hostID, err)
+	}
+	return &host, nil
+}
+
+// FindHosts returns the hostId of every document matching q. Groups are
+// matched against the groups array, labels against fields of labels.
+func (r *MongoHostRepository) FindHosts(ctx context.Context, q HostQuery) ([]int, error) {
+	filter := bson.M{}
+	if q.Group != "" {
+		filter["groups"] = q.Group
+	}
+	for k, v := range q.Labels {
+		filter["labels."+k] = v
+	}
+	cursor, err := r.Collection.Find(ctx, filter, options.Find().SetProjection(bson.M{"hostId": 1}))
+	if err != nil {
+		return nil, fmt.Errorf("MongoDB Find failed: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var ids []int
+	for cursor.Next(ctx) {
+		var doc bson.M
+		if err := cursor.Decode(&doc); err != nil {
+			return nil, fmt.Errorf("failed to decode host: %w", err)
+		}
+		id, err := intField(doc["hostId"])
+		if err != nil {
+			return nil, fmt.Errorf("host %v: %w", doc["_id"], err)
+		}
+		ids = append(ids, id)
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, fmt.Errorf("MongoDB cursor failed: %w", err)
+	}
+	sort.Ints(ids)
+	return ids, nil
+}
+
+// intField converts an id stored as a string or a number.
+func intField(v any) (int, error) {
+	switch t := v.(type) {
+	case int32:
+		return int(t), nil
+	case int64:
+		return int(t), nil
+	case float64:
+		return int(t), nil
+	case string:
+		return strconv.Atoi(t)
+	}
+	return 0, fmt.Errorf("invalid hostId %v", v)
+}
+
+func idFilter(field string, id int) bson.M {
+	return bson.M{"$or": bson.A{
+		bson.M{field: strconv.Itoa(id)},
+		bson.M{field: id},
+	}}
+}
+
+// findJSONDocument decodes the matching document through relaxed extended
+// JSON, so the json tags of the graphproc types apply to stored documents too.
+// Fields listed in omit are dropped first; the lookup id may be stored with a
+// different type than the target field.
+func findJSONDocument(ctx context.Context, coll *mongo.Collection, filter any, out any, omit ...string) error {
+	var doc bson.M
+	err := coll.FindOne(ctx, filter).Decode(&doc)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("MongoDB FindOne failed: %w", err)
+	}
+	for _, field := range omit {
+		delete(doc, field)
+	}
+	data, err := bson.MarshalExtJSON(doc, false, false)
+	if err != nil {
+		return fmt.Errorf("failed to convert document: %w", err)
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("failed to decode document: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+// Package repository loads the script graphs and host configurations a
+// collection request refers to.
+package repository
+
+import (
+	"context"
+	"errors"
+	"slices"
+
+	gp "github.com/andrej220/HAM/pkg/graphproc"
+)
+
+var ErrNotFound = errors.New("not found")
+
+// ScriptRepository returns script documents (see the Script schema) by scriptId.
+type ScriptRepository interface {
+	GetScript(ctx context.Context, scriptID int) (*gp.Config, error)
+}
+
+// HostRepository returns host configurations by hostId.
+type HostRepository interface {
+	GetHost(ctx context.Context, hostID int) (*gp.HostConfig, error)
+}
+
+// HostQuery selects hosts by device group and labels. A host matches when
+// it is in the group and carries every label with the given value.
+type HostQuery struct {
+	Group  string
+	Labels map[string]string
+}
+
+// Matches reports whether host is selected by q.
+func (q HostQuery) Matches(host *gp.HostConfig) bool {
+	if q.Group != "" && !slices.Contains(host.Groups, q.Group) {
+		return false
+	}
+	for k, v := range q.Labels {
+		if hv, ok := host.Labels[k]; !ok || hv != v {
+			return false
+		}
+	}
+	return true
+}
+
+// HostSelector finds the hosts matching a query, for batch requests.
+type HostSelector interface {
+	// FindHosts returns the matching host IDs in ascending order.
+	FindHosts(ctx context.Context, q HostQuery) ([]int, error)
+}
+
+// LoadGraph builds the graph for running scriptID on hostID. A host that
+// is not in the repository is ErrNotFound, unless inlineHosts is set: then
+// it falls back to the remote_host, login and password of the script
+// document, if it has a remote_host. That is meant for development only,
+// the script runs on the same machine whatever hostID is asked for.
+func LoadGraph(ctx context.Context, scripts ScriptRepository, hosts HostRepository, scriptID, hostID int, inlineHosts bool) (*gp.Graph, error) {
+	cfg, err := scripts.GetScript(ctx, scriptID)
+	if err != nil {
+		return nil, err
+	}
+	host, err := hosts.GetHost(ctx, hostID)
+	if errors.Is(err, ErrNotFound) && inlineHosts && cfg.RemoteHost != "" {
+		host, err = &gp.HostConfig{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	host.HostID = hostID
+	host.ScriptID = scriptID
+
+	graph := gp.NewGraph(cfg)
+	graph.HostCfg = host
+	return graph, nil
+}
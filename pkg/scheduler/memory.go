@@ -0,0 +1,111 @@
+package scheduler
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+var _ Store = (*MemoryStore)(nil)
+
+// MemoryStore keeps schedules in memory, for tests. Schedules do not
+// survive a restart.
+type MemoryStore struct {
+	mu        sync.Mutex
+	schedules map[string]Schedule
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{schedules: make(map[string]Schedule)}
+}
+
+func (m *MemoryStore) Create(_ context.Context, s *Schedule) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.schedules[s.ID]; ok {
+		return ErrExists
+	}
+	m.schedules[s.ID] = *s
+	return nil
+}
+
+func (m *MemoryStore) Get(_ context.Context, id string) (*Schedule, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.schedules[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &s, nil
+}
+
+func (m *MemoryStore) List(_ context.Context) ([]Schedule, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	list := make([]Schedule, 0, len(m.schedules))
+	for _, s := range m.schedules {
+		list = append(list, s)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.Before(list[j].CreatedAt) })
+	return list, nil
+}
+
+func (m *MemoryStore) Update(_ context.Context, s *Schedule) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	old, ok := m.schedules[s.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	u := *s
+	u.CreatedAt, u.LastRun, u.LastError = old.CreatedAt, old.LastRun, old.LastError
+	m.schedules[s.ID] = u
+	return nil
+}
+
+func (m *MemoryStore) Delete(_ context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.schedules[id]; !ok {
+		return ErrNotFound
+	}
+	delete(m.schedules, id)
+	return nil
+}
+
+func (m *MemoryStore) Due(_ context.Context, now time.Time) ([]Schedule, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var due []Schedule
+	for _, s := range m.schedules {
+		if s.Enabled && !s.NextRun.IsZero() && !s.NextRun.After(now) {
+			due = append(due, s)
+		}
+	}
+	return due, nil
+}
+
+func (m *MemoryStore) Claim(_ context.Context, id string, prev, next, now time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.schedules[id]
+	if !ok || !s.Enabled || !s.NextRun.Equal(prev) {
+		return false, nil
+	}
+	s.NextRun, s.LastRun = next, now
+	m.schedules[id] = s
+	return true, nil
+}
+
+func (m *MemoryStore) SetLastError(_ context.Context, id, msg string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.schedules[id]
+	if !ok {
+		return ErrNotFound
+	}
+	s.LastError = msg
+	m.schedules[id] = s
+	return nil
+}
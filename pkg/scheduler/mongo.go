@@ -0,0 +1,125 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var _ Store = (*MongoStore)(nil)
+
+// MongoStore keeps one document per schedule. Claims are conditional
+// updates on nextRun, so only one scheduler wins each firing.
+type MongoStore struct {
+	Collection *mongo.Collection
+}
+
+func NewMongoStore(collection *mongo.Collection) *MongoStore {
+	return &MongoStore{Collection: collection}
+}
+
+// EnsureIndexes creates the index Due queries on.
+func (m *MongoStore) EnsureIndexes(ctx context.Context) error {
+	_, err := m.Collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "enabled", Value: 1}, {Key: "nextRun", Value: 1}},
+	})
+	return err
+}
+
+func (m *MongoStore) Create(ctx context.Context, s *Schedule) error {
+	_, err := m.Collection.InsertOne(ctx, s)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrExists
+	}
+	if err != nil {
+		return fmt.Errorf("MongoDB InsertOne failed: %w", err)
+	}
+	return nil
+}
+
+func (m *MongoStore) Get(ctx context.Context, id string) (*Schedule, error) {
+	var s Schedule
+	err := m.Collection.FindOne(ctx, bson.M{"_id": id}).Decode(&s)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("MongoDB FindOne failed: %w", err)
+	}
+	return &s, nil
+}
+
+func (m *MongoStore) List(ctx context.Context) ([]Schedule, error) {
+	return m.find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}}))
+}
+
+func (m *MongoStore) Update(ctx context.Context, s *Schedule) error {
+	res, err := m.Collection.UpdateOne(ctx, bson.M{"_id": s.ID}, bson.M{"$set": bson.M{
+		"cron":      s.Cron,
+		"hostId":    s.HostID,
+		"group":     s.Group,
+		"scriptId":  s.ScriptID,
+		"jitter":    s.Jitter,
+		"enabled":   s.Enabled,
+		"nextRun":   s.NextRun,
+		"updatedAt": s.UpdatedAt,
+	}})
+	if err != nil {
+		return fmt.Errorf("MongoDB UpdateOne failed: %w", err)
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (m *MongoStore) Delete(ctx context.Context, id string) error {
+	res, err := m.Collection.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return fmt.Errorf("MongoDB DeleteOne failed: %w", err)
+	}
+	if res.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (m *MongoStore) Due(ctx context.Context, now time.Time) ([]Schedule, error) {
+	return m.find(ctx, bson.M{"enabled": true, "nextRun": bson.M{"$gt": time.Time{}, "$lte": now}})
+}
+
+func (m *MongoStore) Claim(ctx context.Context, id string, prev, next, now time.Time) (bool, error) {
+	res, err := m.Collection.UpdateOne(ctx,
+		bson.M{"_id": id, "enabled": true, "nextRun": prev},
+		bson.M{"$set": bson.M{"nextRun": next, "lastRun": now}})
+	if err != nil {
+		return false, fmt.Errorf("MongoDB UpdateOne failed: %w", err)
+	}
+	return res.MatchedCount > 0, nil
+}
+
+func (m *MongoStore) SetLastError(ctx context.Context, id, msg string) error {
+	_, err := m.Collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"lastError": msg}})
+	if err != nil {
+		return fmt.Errorf("MongoDB UpdateOne failed: %w", err)
+	}
+	return nil
+}
+
+func (m *MongoStore) find(ctx context.Context, filter bson.M, opts ...*options.FindOptions) ([]Schedule, error) {
+	cursor, err := m.Collection.Find(ctx, filter, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("MongoDB Find failed: %w", err)
+	}
+	defer cursor.Close(ctx)
+	list := []Schedule{}
+	if err := cursor.All(ctx, &list); err != nil {
+		return nil, fmt.Errorf("MongoDB cursor failed: %w", err)
+	}
+	return list, nil
+}
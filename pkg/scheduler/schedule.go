@@ -0,0 +1,78 @@
+// Package scheduler fires collection requests on cron schedules. Every
+// firing is claimed in the shared store before it is published, so
+// restarts and concurrent schedulers do not fire a slot twice.
+//
+// Firings are at most once: the claim moves the schedule to its next slot
+// before the requests are published, and a slot whose publishing fails,
+// or whose scheduler dies between claim and publish, is not retried. The
+// failure is recorded in the schedule's LastError and the schedule fires
+// again at its next slot.
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	gp "github.com/andrej220/HAM/pkg/graphproc"
+)
+
+var (
+	ErrNotFound = errors.New("schedule not found")
+	ErrExists   = errors.New("schedule already exists")
+)
+
+// Schedule runs a script on one host or on every host of a device group.
+type Schedule struct {
+	ID       string      `bson:"_id" json:"id"`
+	Cron     string      `bson:"cron" json:"cron"`
+	HostID   int         `bson:"hostId,omitempty" json:"hostid,omitempty"`
+	Group    string      `bson:"group,omitempty" json:"group,omitempty"`
+	ScriptID int         `bson:"scriptId" json:"scriptid"`
+	Jitter   gp.Duration `bson:"jitter" json:"jitter"` // random delay added to every firing
+	Enabled  bool        `bson:"enabled" json:"enabled"`
+
+	// NextRun is the time of the next firing, jitter included; zero while
+	// the schedule is disabled.
+	NextRun   time.Time `bson:"nextRun" json:"nextRun"`
+	LastRun   time.Time `bson:"lastRun,omitempty" json:"lastRun,omitempty"`
+	LastError string    `bson:"lastError,omitempty" json:"lastError,omitempty"`
+	CreatedAt time.Time `bson:"createdAt" json:"createdAt"`
+	UpdatedAt time.Time `bson:"updatedAt" json:"updatedAt"`
+}
+
+// Validate checks the definition of s.
+func (s *Schedule) Validate() error {
+	if _, err := ParseCron(s.Cron); err != nil {
+		return err
+	}
+	if s.ScriptID <= 0 {
+		return errors.New("scriptid is required")
+	}
+	if (s.HostID > 0) == (s.Group != "") {
+		return errors.New("exactly one of hostid and group is required")
+	}
+	if s.Jitter < 0 {
+		return fmt.Errorf("negative jitter %v", time.Duration(s.Jitter))
+	}
+	return nil
+}
+
+// Store persists schedules. Times are stored with second precision.
+type Store interface {
+	Create(ctx context.Context, s *Schedule) error
+	Get(ctx context.Context, id string) (*Schedule, error)
+	List(ctx context.Context) ([]Schedule, error)
+	// Update replaces the definition and next run of a schedule.
+	Update(ctx context.Context, s *Schedule) error
+	Delete(ctx context.Context, id string) error
+	// Due returns the enabled schedules whose next run is not after now.
+	Due(ctx context.Context, now time.Time) ([]Schedule, error)
+	// Claim moves the next run of a schedule from prev to next and records
+	// now as its last run. It reports false if the next run is no longer
+	// prev, i.e. another scheduler fired it or the schedule was changed.
+	Claim(ctx context.Context, id string, prev, next, now time.Time) (bool, error)
+	// SetLastError records the outcome of the last firing.
+	SetLastError(ctx context.Context, id, msg string) error
+}
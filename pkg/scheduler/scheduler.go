@@ -0,0 +1,121 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand"
+	"time"
+)
+
+const DefaultInterval = 15 * time.Second
+
+// FireFunc publishes the collection requests of one firing of s.
+type FireFunc func(ctx context.Context, s *Schedule) error
+
+// Scheduler polls the store for due schedules and fires them. Each firing
+// is claimed before fire is called, so a slot fires at most once even with
+// several schedulers on one store; a failed fire is not retried. Slots
+// missed while no scheduler ran are collapsed into a single firing.
+type Scheduler struct {
+	store    Store
+	fire     FireFunc
+	interval time.Duration
+	now      func() time.Time
+	jitter   func(max time.Duration) time.Duration
+}
+
+func New(store Store, fire FireFunc, interval time.Duration) *Scheduler {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	return &Scheduler{
+		store:    store,
+		fire:     fire,
+		interval: interval,
+		now:      time.Now,
+		jitter: func(max time.Duration) time.Duration {
+			return time.Duration(rand.Int63n(int64(max/time.Second)+1)) * time.Second
+		},
+	}
+}
+
+// Plan sets the next run of sch from the current time: the next cron slot
+// plus a random jitter, or zero if the schedule is disabled.
+func (s *Scheduler) Plan(sch *Schedule) error {
+	next, err := s.nextRun(sch, s.now())
+	if err != nil {
+		return err
+	}
+	sch.NextRun = next
+	return nil
+}
+
+func (s *Scheduler) nextRun(sch *Schedule, after time.Time) (time.Time, error) {
+	if !sch.Enabled {
+		return time.Time{}, nil
+	}
+	c, err := ParseCron(sch.Cron)
+	if err != nil {
+		return time.Time{}, err
+	}
+	next := c.Next(after.UTC())
+	if sch.Jitter > 0 {
+		next = next.Add(s.jitter(time.Duration(sch.Jitter)))
+	}
+	return next, nil
+}
+
+// Run fires due schedules every interval until ctx is done.
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		if _, err := s.Tick(ctx); err != nil {
+			log.Printf("Scheduler: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Tick fires the schedules due now and returns how many it fired.
+func (s *Scheduler) Tick(ctx context.Context) (int, error) {
+	now := s.now().UTC().Truncate(time.Second)
+	due, err := s.store.Due(ctx, now)
+	if err != nil {
+		return 0, fmt.Errorf("load due schedules: %w", err)
+	}
+	fired := 0
+	for i := range due {
+		sch := &due[i]
+		next, err := s.nextRun(sch, now)
+		if err != nil {
+			log.Printf("Scheduler: schedule %s: %v", sch.ID, err)
+			continue
+		}
+		ok, err := s.store.Claim(ctx, sch.ID, sch.NextRun, next, now)
+		if err != nil {
+			log.Printf("Scheduler: claim schedule %s: %v", sch.ID, err)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		fired++
+		var msg string
+		if err := s.fire(ctx, sch); err != nil {
+			log.Printf("Scheduler: fire schedule %s: %v", sch.ID, err)
+			msg = err.Error()
+		}
+		if msg != "" || sch.LastError != "" {
+			if err := s.store.SetLastError(ctx, sch.ID, msg); err != nil {
+				log.Printf("Scheduler: schedule %s: %v", sch.ID, err)
+			}
+		}
+	}
+	return fired, nil
+}
@@ -0,0 +1,142 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+type clock struct{ t time.Time }
+
+func (c *clock) now() time.Time { return c.t }
+
+func newTestScheduler(store Store, c *clock, fire FireFunc) *Scheduler {
+	s := New(store, fire, time.Second)
+	s.now = c.now
+	s.jitter = func(time.Duration) time.Duration { return 0 }
+	return s
+}
+
+func addSchedule(t *testing.T, s *Scheduler, store Store, sch Schedule) {
+	t.Helper()
+	if err := sch.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Plan(&sch); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Create(context.Background(), &sch); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSchedulerFiresOncePerSlot(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	c := &clock{t: time.Date(2025, 1, 15, 10, 7, 0, 0, time.UTC)}
+	var fired []string
+	s := newTestScheduler(store, c, func(_ context.Context, sch *Schedule) error {
+		fired = append(fired, sch.ID)
+		return nil
+	})
+	addSchedule(t, s, store, Schedule{ID: "a", Cron: "*/5 * * * *", HostID: 1, ScriptID: 2, Enabled: true})
+	addSchedule(t, s, store, Schedule{ID: "off", Cron: "* * * * *", HostID: 1, ScriptID: 2})
+
+	if n, _ := s.Tick(ctx); n != 0 {
+		t.Fatalf("fired %d before the slot", n)
+	}
+	c.t = time.Date(2025, 1, 15, 10, 10, 5, 0, time.UTC)
+	if n, _ := s.Tick(ctx); n != 1 {
+		t.Fatalf("fired %d at the slot, want 1", n)
+	}
+	// a second tick or scheduler in the same slot does not fire again
+	if n, _ := s.Tick(ctx); n != 0 {
+		t.Fatalf("fired %d twice in one slot", n)
+	}
+	sch, _ := store.Get(ctx, "a")
+	if want := time.Date(2025, 1, 15, 10, 15, 0, 0, time.UTC); !sch.NextRun.Equal(want) {
+		t.Errorf("NextRun = %v, want %v", sch.NextRun, want)
+	}
+
+	// missed slots collapse into one firing
+	c.t = time.Date(2025, 1, 15, 11, 2, 0, 0, time.UTC)
+	if n, _ := s.Tick(ctx); n != 1 {
+		t.Fatalf("fired %d after downtime, want 1", n)
+	}
+	if len(fired) != 2 || fired[0] != "a" || fired[1] != "a" {
+		t.Errorf("fired = %v", fired)
+	}
+}
+
+func TestSchedulerConcurrentClaims(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	c := &clock{t: time.Date(2025, 1, 15, 10, 0, 30, 0, time.UTC)}
+	var mu sync.Mutex
+	fired := 0
+	fire := func(context.Context, *Schedule) error {
+		mu.Lock()
+		defer mu.Unlock()
+		fired++
+		return nil
+	}
+	first := newTestScheduler(store, c, fire)
+	addSchedule(t, first, store, Schedule{ID: "a", Cron: "@hourly", Group: "core", ScriptID: 2, Enabled: true})
+	c.t = time.Date(2025, 1, 15, 11, 0, 1, 0, time.UTC)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		s := newTestScheduler(store, c, fire)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.Tick(ctx)
+		}()
+	}
+	wg.Wait()
+	if fired != 1 {
+		t.Errorf("fired %d times, want 1", fired)
+	}
+}
+
+func TestSchedulerRecordsErrors(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	c := &clock{t: time.Date(2025, 1, 15, 10, 0, 30, 0, time.UTC)}
+	fail := true
+	s := newTestScheduler(store, c, func(context.Context, *Schedule) error {
+		if fail {
+			return errors.New("kafka down")
+		}
+		return nil
+	})
+	addSchedule(t, s, store, Schedule{ID: "a", Cron: "* * * * *", HostID: 1, ScriptID: 2, Enabled: true})
+
+	c.t = c.t.Add(time.Minute)
+	s.Tick(ctx)
+	if sch, _ := store.Get(ctx, "a"); sch.LastError != "kafka down" {
+		t.Errorf("LastError = %q", sch.LastError)
+	}
+	fail = false
+	c.t = c.t.Add(time.Minute)
+	s.Tick(ctx)
+	if sch, _ := store.Get(ctx, "a"); sch.LastError != "" {
+		t.Errorf("LastError = %q after a successful firing", sch.LastError)
+	}
+}
+
+func TestScheduleValidate(t *testing.T) {
+	for _, sch := range []Schedule{
+		{Cron: "bad", HostID: 1, ScriptID: 1},
+		{Cron: "@daily", HostID: 1},
+		{Cron: "@daily", ScriptID: 1},
+		{Cron: "@daily", HostID: 1, Group: "core", ScriptID: 1},
+		{Cron: "@daily", HostID: 1, ScriptID: 1, Jitter: -1},
+	} {
+		if err := sch.Validate(); err == nil {
+			t.Errorf("Validate(%+v) succeeded", sch)
+		}
+	}
+}
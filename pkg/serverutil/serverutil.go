@@ -136,4 +136,25 @@ func respondWithValidationError(rw http.ResponseWriter, err error) {
 
 func defaultValidator[T any](req *T) error {
 	return nil
+}
+
+// ErrorResponse mirrors the Error schema of the public API.
+type ErrorResponse struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// RespondWithJSON writes v as a JSON body with the given status code.
+func RespondWithJSON(rw http.ResponseWriter, status int, v any) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(status)
+	if v == nil {
+		return
+	}
+	_ = json.NewEncoder(rw).Encode(v)
+}
+
+// RespondWithError writes an ErrorResponse with the given status code.
+func RespondWithError(rw http.ResponseWriter, status int, code, message string) {
+	RespondWithJSON(rw, status, ErrorResponse{Code: code, Message: message})
 }
\ No newline at end of file
@@ -1,6 +1,8 @@
 package datamodels
 
 import(
+	"time"
+
 	"github.com/google/uuid"	
 )
 
@@ -8,9 +10,46 @@ type Request struct {
 	HostID   int `json:"hostid"`
 	ScriptID int `json:"scriptid"`
 	ExecutionUID uuid.UUID `json:"exuid"`
+	BatchID  uuid.UUID `json:"batchid"`	// uuid.Nil unless the request is part of a batch
 }
 
 type Response struct {
 	ExecutionUID uuid.UUID `json:"exuid"`
 }
 
+// BatchRequest asks for one script to be collected from many hosts: those
+// listed in HostIDs plus those matching Group and Labels.
+type BatchRequest struct {
+	ScriptID int               `json:"scriptid"`
+	HostIDs  []int             `json:"hostids,omitempty"`
+	Group    string            `json:"group,omitempty"`	// device group
+	Labels   map[string]string `json:"labels,omitempty"`	// label selector, all must match
+}
+
+// BatchExecution is the execution of a batch on one host.
+type BatchExecution struct {
+	HostID       int       `json:"hostid"`
+	ExecutionUID uuid.UUID `json:"exuid"`
+	Status       string    `json:"status,omitempty"`	// set in BatchStatus
+	Error        string    `json:"error,omitempty"`	// why a failed execution has no result, set in BatchStatus
+}
+
+// Batch lists the executions a batch request fanned out into. The producer
+// returns it and registers it with the dataservice.
+type Batch struct {
+	BatchID    uuid.UUID        `json:"batchid"`
+	ScriptID   int              `json:"scriptid"`
+	Executions []BatchExecution `json:"executions"`
+	CreatedAt  time.Time        `json:"createdAt"`
+}
+
+// Batch execution status for executions without a stored result.
+const BatchPending = "pending"
+
+// BatchStatus is a batch with the outcome of every execution: the job
+// status of its stored result, failed if it failed before delivering
+// one, or pending.
+type BatchStatus struct {
+	Batch
+	Counts map[string]int `json:"counts"`	// executions per status
+}
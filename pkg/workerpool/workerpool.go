@@ -20,7 +20,7 @@ type Job[T any] struct{
 	Payload 	T
 	Fn			JobFunc[T]
 	Ctx			context.Context
-	CleanupFunc func()
+	CleanupFunc func()	// runs once Fn has returned, also when Ctx is canceled first
 }
 
 type Pool[T any] struct {
@@ -98,7 +98,12 @@ func (p *Pool[T]) worker(job Job[T]) {
 				doneCh <- nil
 				return
 			}
-			time.Sleep(time.Duration(attempt) * time.Second)
+			select {
+			case <-time.After(time.Duration(attempt) * time.Second):
+			case <-job.Ctx.Done():
+				doneCh <- err
+				return
+			}
 		}
 		doneCh <- fmt.Errorf("failed after 3 attempts: %w", err)
 	}()
@@ -109,6 +114,8 @@ func (p *Pool[T]) worker(job Job[T]) {
 		logger.Info( fmt.Sprintf("Job canceled with payload: %+v, reason: %v", 
 								lg.Any("job",job.Payload), 
 								job.Ctx.Err()) )
+		// Fn may still be running; the cleanup must see how it ended
+		<-doneCh
 	case err := <-doneCh:
 		if err != nil {
 			//log.Printf("Worker error with payload %+v: %v", job.Payload, err)
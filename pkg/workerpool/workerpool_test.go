@@ -0,0 +1,73 @@
+package workerpool
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCleanupWaitsForCanceledJob(t *testing.T) {
+	p := NewPool[int](1)
+	defer p.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	release := make(chan struct{})
+	var finished atomic.Bool
+	cleaned := make(chan bool, 1)
+	p.Submit(Job[int]{
+		Payload: 1,
+		Fn: func(int) error {
+			<-release
+			finished.Store(true)
+			return nil
+		},
+		Ctx:         ctx,
+		CleanupFunc: func() { cleaned <- finished.Load() },
+	})
+
+	<-ctx.Done()
+	select {
+	case <-cleaned:
+		t.Fatal("cleanup ran while Fn was still running")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	select {
+	case ok := <-cleaned:
+		if !ok {
+			t.Error("cleanup did not see the outcome of Fn")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("cleanup did not run")
+	}
+}
+
+func TestCanceledJobIsNotRetried(t *testing.T) {
+	p := NewPool[int](1)
+	defer p.Stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var calls atomic.Int32
+	cleaned := make(chan struct{})
+	p.Submit(Job[int]{
+		Payload: 1,
+		Fn: func(int) error {
+			calls.Add(1)
+			cancel()
+			return context.Canceled
+		},
+		Ctx:         ctx,
+		CleanupFunc: func() { close(cleaned) },
+	})
+
+	select {
+	case <-cleaned:
+	case <-time.After(time.Second):
+		t.Fatal("cleanup did not run")
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("Fn called %d times, want 1", n)
+	}
+}